@@ -0,0 +1,502 @@
+// Package awostest checks that a Client behaves like the backends of awos, see RunConformance.
+package awostest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shimohq/awos/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns the Client under test, it may be shared between the tests and may have Shards.
+// NotFoundAsNil must not be set.
+type Factory func(t *testing.T) awos.Client
+
+// RunConformance checks the semantics of every Client method which the backends of awos agree on.
+// The keys are written under a prefix unique to the run and deleted afterwards, and end with different characters
+// so that they spread over the shards.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *suite)
+	}{
+		{"MissingKey", testMissingKey},
+		{"PutGet", testPutGet},
+		{"Metadata", testMetadata},
+		{"Compression", testCompression},
+		{"Range", testRange},
+		{"List", testList},
+		{"ListObject", testListObject},
+		{"DelMulti", testDelMulti},
+		{"UploadDownload", testUploadDownload},
+		{"SignURL", testSignURL},
+		{"Context", testContext},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := &suite{
+				client: factory(t),
+				prefix: fmt.Sprintf("awostest/%s/%d/", test.name, time.Now().UnixNano()),
+			}
+			t.Cleanup(s.cleanup)
+			test.fn(t, s)
+		})
+	}
+}
+
+type suite struct {
+	client awos.Client
+	prefix string
+
+	mu   sync.Mutex
+	keys []string
+}
+
+// key returns the key of name under the prefix of the test, it's deleted by cleanup
+func (s *suite) key(name string) string {
+	key := s.prefix + name
+	s.mu.Lock()
+	s.keys = append(s.keys, key)
+	s.mu.Unlock()
+	return key
+}
+
+func (s *suite) put(t *testing.T, name string, content string) string {
+	key := s.key(name)
+	require.NoError(t, s.client.Put(key, strings.NewReader(content), nil))
+	return key
+}
+
+func (s *suite) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) > 0 {
+		_ = s.client.DelMulti(s.keys)
+	}
+}
+
+func testMissingKey(t *testing.T, s *suite) {
+	key := s.key("missing")
+
+	_, err := s.client.Get(key)
+	assert.True(t, awos.IsNotFound(err), "Get: %v", err)
+	_, err = s.client.GetBytes(key)
+	assert.True(t, awos.IsNotFound(err), "GetBytes: %v", err)
+	_, err = s.client.GetAsReader(key)
+	assert.True(t, awos.IsNotFound(err), "GetAsReader: %v", err)
+	_, _, err = s.client.GetWithMeta(key, []string{"test-key"})
+	assert.True(t, awos.IsNotFound(err), "GetWithMeta: %v", err)
+	_, _, err = s.client.GetWithMetaGZIP(key, []string{"test-key"})
+	assert.True(t, awos.IsNotFound(err), "GetWithMetaGZIP: %v", err)
+	_, err = s.client.Head(key, []string{"test-key"})
+	assert.True(t, awos.IsNotFound(err), "Head: %v", err)
+	_, err = s.client.Range(key, 0, 1)
+	assert.True(t, awos.IsNotFound(err), "Range: %v", err)
+	_, err = s.client.GetAndDecompress(key)
+	assert.True(t, awos.IsNotFound(err), "GetAndDecompress: %v", err)
+	err = s.client.Download(key, &WriterAt{})
+	assert.True(t, awos.IsNotFound(err), "Download: %v", err)
+
+	ok, err := s.client.Exists(key)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, s.client.Del(key), "Del of a missing key")
+}
+
+func testPutGet(t *testing.T, s *suite) {
+	key := s.put(t, "a", "content")
+
+	res, err := s.client.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", res)
+
+	data, err := s.client.GetBytes(key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+
+	r, err := s.client.GetAsReader(key)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "content", string(data))
+
+	ok, err := s.client.Exists(key)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// overwrite
+	require.NoError(t, s.client.Put(key, strings.NewReader("new"), nil))
+	res, err = s.client.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", res)
+
+	empty := s.put(t, "b", "")
+	res, err = s.client.Get(empty)
+	assert.NoError(t, err)
+	assert.Equal(t, "", res)
+
+	require.NoError(t, s.client.Del(key))
+	ok, err = s.client.Exists(key)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func testMetadata(t *testing.T, s *suite) {
+	key := s.key("c")
+	err := s.client.Put(key, strings.NewReader("content"), map[string]string{"test-key": "value", "compressor-like": "x"},
+		awos.PutWithContentType("application/json"), awos.PutWithContentEncoding("identity"),
+		awos.PutWithContentDisposition(`attachment; filename="a.json"`))
+	require.NoError(t, err)
+
+	// the lowercase keys with hyphens are returned as given, whatever the casing of the storage is
+	attributes := []string{"test-key", "compressor-like", "Content-Type", "Content-Encoding", "Content-Length", "Content-Disposition"}
+	want := map[string]string{
+		"test-key":            "value",
+		"compressor-like":     "x",
+		"Content-Type":        "application/json",
+		"Content-Encoding":    "identity",
+		"Content-Length":      "7",
+		"Content-Disposition": `attachment; filename="a.json"`,
+	}
+	meta, err := s.client.Head(key, attributes)
+	require.NoError(t, err)
+	for k, v := range want {
+		assert.Equal(t, v, meta[k], "Head %s", k)
+	}
+	// a missing attribute is either left out or empty
+	meta, err = s.client.Head(key, []string{"missing-key"})
+	require.NoError(t, err)
+	assert.Equal(t, "", meta["missing-key"])
+
+	body, meta, err := s.client.GetWithMeta(key, attributes)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "content", string(data))
+	for k, v := range want {
+		assert.Equal(t, v, meta[k], "GetWithMeta %s", k)
+	}
+
+	body, meta, err = s.client.GetWithMetaGZIP(key, attributes)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "content", string(data))
+	for k, v := range want {
+		assert.Equal(t, v, meta[k], "GetWithMetaGZIP %s", k)
+	}
+
+	// the content type of the response is replaced by the GetOptions
+	body, meta, err = s.client.GetWithMeta(key, []string{"Content-Type"}, awos.GetWithContentType("text/plain"))
+	require.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "text/plain", meta["Content-Type"])
+
+	defaultType := s.put(t, "d", "content")
+	meta, err = s.client.Head(defaultType, []string{"Content-Type"})
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", meta["Content-Type"], "the default content type of Put")
+}
+
+func testCompression(t *testing.T, s *suite) {
+	content := strings.Repeat("compressible content ", 100)
+	key := s.key("e")
+	require.NoError(t, s.client.CompressAndPut(key, strings.NewReader(content), map[string]string{"test-key": "value"}))
+
+	raw, err := s.client.GetBytes(key)
+	require.NoError(t, err)
+	assert.Less(t, len(raw), len(content), "CompressAndPut stores the compressed content")
+
+	res, err := s.client.GetAndDecompress(key)
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+
+	r, err := s.client.GetAndDecompressAsReader(key)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, content, string(data))
+
+	meta, err := s.client.Head(key, []string{awos.MetaCompressor, "test-key"})
+	require.NoError(t, err)
+	assert.Equal(t, "snappy", meta[awos.MetaCompressor])
+	assert.Equal(t, "value", meta["test-key"])
+
+	// objects written by Put are returned as is
+	plain := s.put(t, "f", content)
+	res, err = s.client.GetAndDecompress(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+
+	zstd := s.key("z")
+	require.NoError(t, s.client.CompressAndPut(zstd, strings.NewReader(content), nil, awos.PutWithCompressType("zstd")))
+	res, err = s.client.GetAndDecompress(zstd)
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+	meta, err = s.client.Head(zstd, []string{awos.MetaCompressor})
+	require.NoError(t, err)
+	assert.Equal(t, "zstd", meta[awos.MetaCompressor])
+
+	// objects written with a Content-Encoding, e.g. by EnableCompressor, are decoded too
+	encoded, _, err := awos.DefaultGzipCompressor.Compress(strings.NewReader(content))
+	require.NoError(t, err)
+	gzip := s.key("y")
+	require.NoError(t, s.client.Put(gzip, encoded, nil, awos.PutWithContentEncoding("gzip")))
+	r, err = s.client.GetAndDecompressAsReader(gzip)
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, content, string(data))
+
+	// GetWithMetaGZIP returns the content as stored, with its Content-Encoding
+	_, err = encoded.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	stored, err := ioutil.ReadAll(encoded)
+	require.NoError(t, err)
+	r, meta, err = s.client.GetWithMetaGZIP(gzip, []string{"Content-Encoding"})
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, stored, data)
+	assert.Equal(t, "gzip", meta["Content-Encoding"])
+
+	// CompressAndPut on a client with EnableCompressor stores the snappy content in the gzip Content-Encoding
+	encoded, _, err = awos.DefaultSnappyCompressor.Compress(strings.NewReader(content))
+	require.NoError(t, err)
+	encoded, _, err = awos.DefaultGzipCompressor.Compress(encoded)
+	require.NoError(t, err)
+	layered := s.key("x")
+	require.NoError(t, s.client.Put(layered, encoded, map[string]string{awos.MetaCompressor: "snappy"},
+		awos.PutWithContentEncoding("gzip")))
+	res, err = s.client.GetAndDecompress(layered)
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+}
+
+func testRange(t *testing.T, s *suite) {
+	key := s.put(t, "g", "0123456789")
+	read := func(offset int64, length int64) (string, error) {
+		r, err := s.client.Range(key, offset, length)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		return string(data), err
+	}
+
+	res, err := read(0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", res)
+	res, err = read(2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "234", res)
+	res, err = read(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", res)
+	res, err = read(9, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "9", res)
+	// the range is cut at the end of the object
+	res, err = read(8, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "89", res)
+	// a range starting after the end is not satisfiable
+	_, err = read(10, 1)
+	assert.Error(t, err, "Range after the end")
+}
+
+func testList(t *testing.T, s *suite) {
+	names := []string{"a", "b/1", "b/2", "c", "d/1/2", "e-f", "g"}
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, s.put(t, name, name))
+	}
+	sort.Strings(keys)
+	ctx := context.Background()
+
+	var listed []string
+	input := awos.ListInput{Prefix: s.prefix, MaxKeys: 2, AllShards: true}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(keys), "List does not end")
+		result, err := s.client.List(ctx, input)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.Objects), 2)
+		for _, object := range result.Objects {
+			listed = append(listed, object.Key)
+			assert.Equal(t, int64(len(strings.TrimPrefix(object.Key, s.prefix))), object.Size, object.Key)
+			assert.NotEmpty(t, object.ETag, object.Key)
+			assert.False(t, strings.HasPrefix(object.ETag, `"`), "ETag without quotes")
+		}
+		if !result.IsTruncated {
+			break
+		}
+		require.NotEmpty(t, result.NextContinuationToken)
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, keys, listed, "List returns every key once in lexical order")
+
+	var objects, prefixes []string
+	input = awos.ListInput{Prefix: s.prefix, MaxKeys: 1, Delimiter: "/", AllShards: true}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, len(keys), "List does not end")
+		result, err := s.client.List(ctx, input)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.Objects)+len(result.CommonPrefixes), 1)
+		for _, object := range result.Objects {
+			objects = append(objects, strings.TrimPrefix(object.Key, s.prefix))
+		}
+		for _, prefix := range result.CommonPrefixes {
+			prefixes = append(prefixes, strings.TrimPrefix(prefix, s.prefix))
+		}
+		if !result.IsTruncated {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"a", "c", "e-f", "g"}, objects)
+	assert.Equal(t, []string{"b/", "d/"}, prefixes)
+
+	result, err := s.client.List(ctx, awos.ListInput{Prefix: s.prefix, Marker: keys[len(keys)-2], AllShards: true})
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1, "List after Marker")
+	assert.Equal(t, keys[len(keys)-1], result.Objects[0].Key)
+	assert.False(t, result.IsTruncated)
+
+	result, err = s.client.List(ctx, awos.ListInput{Prefix: s.prefix + "missing/", AllShards: true})
+	require.NoError(t, err)
+	assert.Empty(t, result.Objects)
+	assert.Empty(t, result.CommonPrefixes)
+	assert.False(t, result.IsTruncated)
+}
+
+// testListObject checks the keys listed by ListObject, which only lists the shard of its key
+func testListObject(t *testing.T, s *suite) {
+	names := []string{"a", "b/1", "b/2", "c", "d/1/2"}
+	keys := make(map[string]bool)
+	for _, name := range names {
+		keys[s.put(t, name, name)] = true
+	}
+	first := s.prefix + "a"
+
+	listed, err := s.client.ListObject(first, s.prefix, "", 0, "")
+	require.NoError(t, err)
+	assert.True(t, sort.StringsAreSorted(listed), "ListObject in lexical order")
+	assert.Contains(t, listed, first)
+	for _, key := range listed {
+		assert.True(t, keys[key], "ListObject returns %s which is not put", key)
+	}
+
+	listed, err = s.client.ListObject(first, s.prefix, first, 0, "")
+	require.NoError(t, err)
+	for _, key := range listed {
+		assert.Greater(t, key, first, "ListObject after marker")
+	}
+
+	listed, err = s.client.ListObject(first, s.prefix, "", 0, "/")
+	require.NoError(t, err)
+	assert.Contains(t, listed, first)
+	for _, key := range listed {
+		assert.NotContains(t, strings.TrimPrefix(key, s.prefix), "/", "the keys grouped by the delimiter are left out")
+	}
+
+	listed, err = s.client.ListObject(first, s.prefix, "", 1, "")
+	require.NoError(t, err)
+	assert.Len(t, listed, 1, "ListObject with maxKeys")
+}
+
+func testDelMulti(t *testing.T, s *suite) {
+	// the last characters spread the keys over the shards routed by the last character
+	var keys []string
+	for _, c := range "0123456789abcdefghijklmnopqrstuvwxyz" {
+		keys = append(keys, s.put(t, "h"+string(c), string(c)))
+	}
+	require.NoError(t, s.client.DelMulti(keys))
+	for _, key := range keys {
+		ok, err := s.client.Exists(key)
+		assert.NoError(t, err)
+		assert.False(t, ok, "%s is deleted", key)
+	}
+	assert.NoError(t, s.client.DelMulti(keys), "DelMulti of missing keys")
+}
+
+func testUploadDownload(t *testing.T, s *suite) {
+	content := strings.Repeat("0123456789", 1000)
+	key := s.key("i")
+	err := s.client.Upload(key, strings.NewReader(content), map[string]string{"test-key": "value"},
+		awos.UploadWithPutOptions(awos.PutWithContentType("application/json")))
+	require.NoError(t, err)
+
+	meta, err := s.client.Head(key, []string{"test-key", "Content-Type", "Content-Length"})
+	require.NoError(t, err)
+	assert.Equal(t, "value", meta["test-key"])
+	assert.Equal(t, "application/json", meta["Content-Type"])
+	assert.Equal(t, fmt.Sprint(len(content)), meta["Content-Length"])
+
+	w := &WriterAt{}
+	require.NoError(t, s.client.Download(key, w, awos.DownloadWithPartSize(3000), awos.DownloadWithConcurrency(2)))
+	assert.Equal(t, content, string(w.Bytes()))
+}
+
+func testSignURL(t *testing.T, s *suite) {
+	key := s.put(t, "j", "content")
+	res, err := s.client.SignURL(key, 60)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, res)
+}
+
+func testContext(t *testing.T, s *suite) {
+	key := s.put(t, "k", "content")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.client.GetWithContext(ctx, key)
+	assert.Error(t, err, "GetWithContext with a canceled context")
+	err = s.client.PutWithContext(ctx, key, strings.NewReader("new"), nil)
+	assert.Error(t, err, "PutWithContext with a canceled context")
+	_, err = s.client.HeadWithContext(ctx, key, nil)
+	assert.Error(t, err, "HeadWithContext with a canceled context")
+
+	res, err := s.client.GetWithContext(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", res, "the canceled Put does not write")
+}
+
+// WriterAt is an in-memory io.WriterAt for Download
+type WriterAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *WriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	copy(w.buf[off:], p)
+	return len(p), nil
+}
+
+// Bytes returns the written content
+func (w *WriterAt) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte{}, w.buf...)
+}
@@ -0,0 +1,105 @@
+package awostest
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/shimohq/awos/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance_Memory(t *testing.T) {
+	RunConformance(t, func(t *testing.T) awos.Client {
+		return awos.NewMemory()
+	})
+}
+
+func TestConformance_File(t *testing.T) {
+	RunConformance(t, func(t *testing.T) awos.Client {
+		client, err := awos.New(&awos.Options{
+			StorageType:     awos.StorageTypeFile,
+			Endpoint:        t.TempDir(),
+			Bucket:          "test",
+			AccessKeySecret: "secret",
+			FileURL:         "http://127.0.0.1/awos",
+		})
+		require.NoError(t, err)
+		return client
+	})
+}
+
+func TestConformance_FileShards(t *testing.T) {
+	RunConformance(t, func(t *testing.T) awos.Client {
+		client, err := awos.New(&awos.Options{
+			StorageType:     awos.StorageTypeFile,
+			Endpoint:        t.TempDir(),
+			Bucket:          "test",
+			Shards:          []string{"0123456789", "abcdefghijklm", "nopqrstuvwxyz"},
+			AccessKeySecret: "secret",
+			FileURL:         "http://127.0.0.1/awos",
+		})
+		require.NoError(t, err)
+		return client
+	})
+}
+
+// testDecompressor checks the Get methods of a client with EnableCompressor and EnableDecompressor
+func testDecompressor(t *testing.T, options *awos.Options) {
+	options.EnableCompressor = true
+	options.CompressType = "gzip"
+	options.CompressLimit = 100
+	options.EnableDecompressor = true
+	client, err := awos.New(options)
+	require.NoError(t, err)
+	content := strings.Repeat("content", 100)
+	require.NoError(t, client.Put("gzip", strings.NewReader(content), nil))
+
+	res, err := client.Get("gzip")
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+	reader, err := client.GetAsReader("gzip")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, content, string(data))
+	reader, meta, err := client.GetWithMeta("gzip", []string{"Content-Encoding"})
+	require.NoError(t, err)
+	data, err = ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "gzip", meta["Content-Encoding"])
+
+	raw, err := client.GetBytes("gzip", awos.GetRaw())
+	assert.NoError(t, err)
+	assert.Less(t, len(raw), len(content))
+	res, err = client.Get("gzip", awos.EnableCRCValidation())
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+
+	// the snappy of CompressAndPut, above the CompressLimit, in the gzip of EnableCompressor
+	var mixed strings.Builder
+	for i := 0; i < 100; i++ {
+		mixed.WriteString(strconv.Itoa(i * i * i))
+	}
+	require.NoError(t, client.CompressAndPut("snappy", strings.NewReader(mixed.String()), nil))
+	meta, err = client.Head("snappy", []string{"Content-Encoding", awos.MetaCompressor})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Content-Encoding": "gzip", awos.MetaCompressor: "snappy"}, meta)
+	res, err = client.GetAndDecompress("snappy")
+	assert.NoError(t, err)
+	assert.Equal(t, mixed.String(), res)
+
+	// Upload never compresses, even a body smaller than a part
+	require.NoError(t, client.Upload("upload", strings.NewReader(content), nil))
+	meta, err = client.Head("upload", []string{"Content-Encoding"})
+	require.NoError(t, err)
+	assert.Empty(t, meta["Content-Encoding"])
+	raw, err = client.GetBytes("upload", awos.GetRaw())
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(raw))
+}
@@ -0,0 +1,91 @@
+package awostest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ossHeaderPrefix = "x-oss-"
+
+// ossSignedParams are the sub-resources in the string to sign of oss signature version 1,
+// the subset of the list of the sdk which the OSSServer may receive
+var ossSignedParams = map[string]bool{
+	"acl": true, "uploads": true, "delete": true, "objectMeta": true, "uploadId": true, "partNumber": true,
+	"security-token": true, "x-oss-process": true, "continuation-token": true, "versionId": true, "tagging": true,
+	"response-content-type": true, "response-content-language": true, "response-expires": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+}
+
+// verifyOSSV1 checks the signature version 1 of the request, from the Authorization header or the query of a signed url
+func verifyOSSV1(r *http.Request, bucket string, key string, accessKeyID string, secret string) error {
+	query := r.URL.Query()
+	var id, signature, date string
+	if query.Has("Signature") {
+		id, signature, date = query.Get("OSSAccessKeyId"), query.Get("Signature"), query.Get("Expires")
+		expires, err := strconv.ParseInt(date, 10, 64)
+		if err != nil || time.Now().Unix() > expires {
+			return errRequestExpired
+		}
+	} else {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "OSS ") {
+			return errMissingSignature
+		}
+		credential := strings.SplitN(strings.TrimPrefix(auth, "OSS "), ":", 2)
+		if len(credential) != 2 {
+			return errMissingSignature
+		}
+		id, signature, date = credential[0], credential[1], r.Header.Get("Date")
+	}
+	if id != accessKeyID {
+		return errInvalidAccessKeyID
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(ossStringToSign(r, bucket, key, date)))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errSignatureMismatch
+	}
+	return nil
+}
+
+func ossStringToSign(r *http.Request, bucket string, key string, date string) string {
+	headers := make([]string, 0)
+	for name, values := range r.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, ossHeaderPrefix) && len(values) > 0 {
+			headers = append(headers, name+":"+values[0]+"\n")
+		}
+	}
+	sort.Strings(headers)
+
+	resource := "/"
+	if bucket != "" {
+		resource = "/" + bucket + "/" + key
+	}
+	query := r.URL.Query()
+	params := make([]string, 0)
+	for name := range query {
+		if ossSignedParams[name] {
+			params = append(params, name)
+		}
+	}
+	sort.Strings(params)
+	for i, name := range params {
+		if v := query.Get(name); v != "" {
+			params[i] += "=" + v
+		}
+	}
+	if len(params) > 0 {
+		resource += "?" + strings.Join(params, "&")
+	}
+
+	return r.Method + "\n" + r.Header.Get("Content-MD5") + "\n" + r.Header.Get("Content-Type") + "\n" + date + "\n" +
+		strings.Join(headers, "") + resource
+}
@@ -0,0 +1,501 @@
+package awostest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shimohq/awos/v3"
+)
+
+const (
+	ossMetaPrefix = "x-oss-meta-"
+	ossCRC64      = "X-Oss-Hash-Crc64ecma"
+	ossRequestID  = "X-Oss-Request-Id"
+	// ossMinPartSize is the minimum size of the parts except the last one
+	ossMinPartSize = 100 << 10
+)
+
+// OSSServer is an httptest.Server speaking enough of the OSS REST API for *awos.OSS:
+// GetObject, PutObject, GetObjectMeta, DeleteObject, DeleteObjects, ListObjects, ListObjectsV2
+// and the multipart uploads. Every bucket exists and is empty at first.
+// The endpoint is an ip, so the sdk puts the bucket in the path.
+// The requests and signed urls are checked by signature version 1 with AccessKeyID and AccessKeySecret,
+// the objects are returned with their CRC64 like oss.
+type OSSServer struct {
+	*httptest.Server
+	AccessKeyID     string
+	AccessKeySecret string
+
+	store     *store
+	requestID uint64
+
+	mu       sync.Mutex
+	uploads  map[string]*multipartUpload
+	uploadID uint64
+}
+
+// NewOSSServer starts an OSSServer, call Close when done
+func NewOSSServer() *OSSServer {
+	s := &OSSServer{
+		AccessKeyID:     "awostest",
+		AccessKeySecret: "awostest-secret",
+		store:           newStore(),
+		uploads:         make(map[string]*multipartUpload),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Options returns the Options of a client of the bucket on the server
+func (s *OSSServer) Options(bucket string) *awos.Options {
+	return &awos.Options{
+		StorageType:     awos.StorageTypeOSS,
+		AccessKeyID:     s.AccessKeyID,
+		AccessKeySecret: s.AccessKeySecret,
+		Endpoint:        s.URL,
+		Bucket:          bucket,
+	}
+}
+
+type ossError struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	RequestID string   `xml:"RequestId"`
+	HostID    string   `xml:"HostId"`
+}
+
+func (s *OSSServer) writeError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	body, _ := xml.Marshal(ossError{Code: code, Message: message, RequestID: w.Header().Get(ossRequestID), HostID: r.Host})
+	body = append([]byte(xml.Header), body...)
+	w.Header().Set("Content-Type", "application/xml")
+	// the response of HEAD has no body, oss puts the error in a header instead
+	if r.Method == http.MethodHead {
+		w.Header().Set("X-Oss-Err", base64.StdEncoding.EncodeToString(body))
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func (s *OSSServer) writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func (s *OSSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(ossRequestID, fmt.Sprintf("%024X", atomic.AddUint64(&s.requestID, 1)))
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	if err := verifyOSSV1(r, bucket, key, s.AccessKeyID, s.AccessKeySecret); err != nil {
+		s.writeError(w, r, http.StatusForbidden, err.Error(), "the signature of the request is invalid")
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if digest := r.Header.Get("Content-MD5"); digest != "" {
+		sum := md5.Sum(body)
+		if digest != base64.StdEncoding.EncodeToString(sum[:]) {
+			s.writeError(w, r, http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified was invalid.")
+			return
+		}
+	}
+	if bucket == "" {
+		s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", "ListBuckets is not supported")
+		return
+	}
+	query := r.URL.Query()
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			s.listObjectsV2(w, r, bucket)
+		case r.Method == http.MethodGet:
+			s.listObjects(w, r, bucket)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			s.deleteObjects(w, r, bucket, body)
+		default:
+			s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", r.Method+" of the bucket is not supported")
+		}
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.initiateMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.completeMultipartUpload(w, r, bucket, key, body)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploadPart(w, r, bucket, key, body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		s.abortMultipartUpload(w, r)
+	case r.Method == http.MethodPut:
+		obj := newOSSObject(r, body)
+		s.store.put(bucket, key, obj)
+		w.Header().Set("ETag", ossETag(obj.etag))
+		w.Header().Set(ossCRC64, strconv.FormatUint(obj.crc64, 10))
+	case r.Method == http.MethodHead && query.Has("objectMeta"):
+		s.getObjectMeta(w, r, bucket, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.store.delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", r.Method+" of the object is not supported")
+	}
+}
+
+// newOSSObject returns the object of the request, with the etag in uppercase like oss
+func newOSSObject(r *http.Request, data []byte) *object {
+	obj := newObject(r, ossMetaPrefix, data)
+	obj.etag = strings.ToUpper(obj.etag)
+	return obj
+}
+
+func ossETag(etag string) string {
+	return `"` + etag + `"`
+}
+
+func (s *OSSServer) object(w http.ResponseWriter, r *http.Request, bucket string, key string) (*object, bool) {
+	obj, ok := s.store.get(bucket, key)
+	if !ok {
+		s.writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return nil, false
+	}
+	return obj, true
+}
+
+// getObjectMeta returns the basic headers of the object only, without the metadata
+func (s *OSSServer) getObjectMeta(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	obj, ok := s.object(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	header := w.Header()
+	header.Set("ETag", ossETag(obj.etag))
+	header.Set("Content-Length", strconv.Itoa(len(obj.data)))
+	header.Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	header.Set(ossCRC64, strconv.FormatUint(obj.crc64, 10))
+}
+
+func (s *OSSServer) getObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	obj, ok := s.object(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	obj.writeHeaders(w, ossMetaPrefix)
+	// the crc64 of the whole object, also for a range
+	w.Header().Set(ossCRC64, strconv.FormatUint(obj.crc64, 10))
+	query := r.URL.Query()
+	for _, name := range []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Cache-Control", "Expires"} {
+		if v := query.Get("response-" + strings.ToLower(name)); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+
+	data := obj.data
+	status := http.StatusOK
+	if header := r.Header.Get("Range"); header != "" {
+		size := int64(len(obj.data))
+		byteRange, ok, satisfiable := parseRange(header, size)
+		standard := strings.EqualFold(r.Header.Get("X-Oss-Range-Behavior"), "standard")
+		if standard && ok && !satisfiable {
+			w.Header().Del("Content-Length")
+			s.writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range cannot be satisfied")
+			return
+		}
+		if ok && satisfiable && (standard || !ossIgnoresRange(header, size)) {
+			data = obj.data[byteRange.start : byteRange.end+1]
+			status = http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", byteRange.start, byteRange.end, size))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+// ossIgnoresRange reports whether oss returns the whole object for the range by default,
+// it does so unless the range is within the object, see x-oss-range-behavior
+func ossIgnoresRange(header string, size int64) bool {
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return false
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	return err != nil || end >= size
+}
+
+type ossContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Type         string `xml:"Type"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type ossCommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// ossEncoder returns the encoder of the keys in the response by encoding-type of the query
+func ossEncoder(query url.Values) func(string) string {
+	if query.Get("encoding-type") == "url" {
+		return url.QueryEscape
+	}
+	return func(s string) string { return s }
+}
+
+// ossListing returns the contents and common prefixes of a page
+func ossListing(items []listed, encode func(string) string) ([]ossContents, []ossCommonPrefix) {
+	contents := make([]ossContents, 0)
+	prefixes := make([]ossCommonPrefix, 0)
+	for _, item := range items {
+		if item.prefix {
+			prefixes = append(prefixes, ossCommonPrefix{Prefix: encode(item.key)})
+			continue
+		}
+		contents = append(contents, ossContents{
+			Key:          encode(item.key),
+			LastModified: item.object.lastModified.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         ossETag(item.object.etag),
+			Type:         "Normal",
+			Size:         len(item.object.data),
+			StorageClass: "Standard",
+		})
+	}
+	return contents, prefixes
+}
+
+func (s *OSSServer) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	encode := ossEncoder(query)
+	prefix, marker, delimiter := query.Get("prefix"), query.Get("marker"), query.Get("delimiter")
+	items, truncated := s.store.list(bucket, prefix, marker, delimiter, maxKeys(query))
+	contents, prefixes := ossListing(items, encode)
+	res := struct {
+		XMLName        xml.Name          `xml:"ListBucketResult"`
+		Name           string            `xml:"Name"`
+		Prefix         string            `xml:"Prefix"`
+		Marker         string            `xml:"Marker"`
+		MaxKeys        int               `xml:"MaxKeys"`
+		Delimiter      string            `xml:"Delimiter"`
+		EncodingType   string            `xml:"EncodingType,omitempty"`
+		IsTruncated    bool              `xml:"IsTruncated"`
+		NextMarker     string            `xml:"NextMarker,omitempty"`
+		Contents       []ossContents     `xml:"Contents"`
+		CommonPrefixes []ossCommonPrefix `xml:"CommonPrefixes"`
+	}{Name: bucket, Prefix: encode(prefix), Marker: encode(marker), MaxKeys: maxKeys(query), Delimiter: encode(delimiter),
+		EncodingType: query.Get("encoding-type"), IsTruncated: truncated, Contents: contents, CommonPrefixes: prefixes}
+	// unlike s3, NextMarker is returned without a delimiter too
+	if truncated {
+		res.NextMarker = encode(items[len(items)-1].key)
+	}
+	s.writeXML(w, res)
+}
+
+func (s *OSSServer) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	encode := ossEncoder(query)
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	after := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := hex.DecodeString(token)
+		if err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect")
+			return
+		}
+		after = string(decoded)
+	}
+	items, truncated := s.store.list(bucket, prefix, after, delimiter, maxKeys(query))
+	contents, prefixes := ossListing(items, encode)
+	res := struct {
+		XMLName               xml.Name          `xml:"ListBucketResult"`
+		Name                  string            `xml:"Name"`
+		Prefix                string            `xml:"Prefix"`
+		StartAfter            string            `xml:"StartAfter,omitempty"`
+		ContinuationToken     string            `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string            `xml:"NextContinuationToken,omitempty"`
+		MaxKeys               int               `xml:"MaxKeys"`
+		Delimiter             string            `xml:"Delimiter"`
+		EncodingType          string            `xml:"EncodingType,omitempty"`
+		IsTruncated           bool              `xml:"IsTruncated"`
+		KeyCount              int               `xml:"KeyCount"`
+		Contents              []ossContents     `xml:"Contents"`
+		CommonPrefixes        []ossCommonPrefix `xml:"CommonPrefixes"`
+	}{Name: bucket, Prefix: encode(prefix), StartAfter: encode(query.Get("start-after")), ContinuationToken: token,
+		MaxKeys: maxKeys(query), Delimiter: encode(delimiter), EncodingType: query.Get("encoding-type"),
+		IsTruncated: truncated, KeyCount: len(items), Contents: contents, CommonPrefixes: prefixes}
+	// the token is opaque to the clients
+	if truncated {
+		res.NextContinuationToken = hex.EncodeToString([]byte(items[len(items)-1].key))
+	}
+	s.writeXML(w, res)
+}
+
+func (s *OSSServer) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	var input struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &input); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	encode := ossEncoder(r.URL.Query())
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	res := struct {
+		XMLName      xml.Name  `xml:"DeleteResult"`
+		EncodingType string    `xml:"EncodingType,omitempty"`
+		Deleted      []deleted `xml:"Deleted"`
+	}{EncodingType: r.URL.Query().Get("encoding-type")}
+	for _, v := range input.Objects {
+		s.store.delete(bucket, v.Key)
+		if !input.Quiet {
+			res.Deleted = append(res.Deleted, deleted{Key: encode(v.Key)})
+		}
+	}
+	s.writeXML(w, res)
+}
+
+func (s *OSSServer) initiateMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	s.mu.Lock()
+	s.uploadID++
+	uploadID := fmt.Sprintf("%032X", s.uploadID)
+	s.uploads[uploadID] = &multipartUpload{bucket: bucket, key: key, request: &http.Request{Header: r.Header.Clone()}, parts: make(map[int]*object)}
+	s.mu.Unlock()
+
+	s.writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (s *OSSServer) upload(w http.ResponseWriter, r *http.Request, bucket string, key string) (*multipartUpload, bool) {
+	uploadID := r.URL.Query().Get("uploadId")
+	s.mu.Lock()
+	upload, ok := s.uploads[uploadID]
+	s.mu.Unlock()
+	if !ok || upload.bucket != bucket || upload.key != key {
+		s.writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return nil, false
+	}
+	return upload, true
+}
+
+func (s *OSSServer) uploadPart(w http.ResponseWriter, r *http.Request, bucket string, key string, body []byte) {
+	upload, ok := s.upload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		s.writeError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000.")
+		return
+	}
+	part := newOSSObject(r, body)
+	s.mu.Lock()
+	upload.parts[partNumber] = part
+	s.mu.Unlock()
+	w.Header().Set("ETag", ossETag(part.etag))
+	w.Header().Set(ossCRC64, strconv.FormatUint(part.crc64, 10))
+}
+
+func (s *OSSServer) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, body []byte) {
+	upload, ok := s.upload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	var input struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &input); err != nil || len(input.Parts) == 0 {
+		s.writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+		return
+	}
+	if !sort.SliceIsSorted(input.Parts, func(i, j int) bool { return input.Parts[i].PartNumber < input.Parts[j].PartNumber }) {
+		s.writeError(w, r, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+		return
+	}
+
+	s.mu.Lock()
+	var data bytes.Buffer
+	sums := make([]byte, 0, md5.Size*len(input.Parts))
+	for i, v := range input.Parts {
+		part, ok := upload.parts[v.PartNumber]
+		if !ok || strings.Trim(v.ETag, `"`) != part.etag {
+			s.mu.Unlock()
+			s.writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		if i < len(input.Parts)-1 && len(part.data) < ossMinPartSize {
+			s.mu.Unlock()
+			s.writeError(w, r, http.StatusBadRequest, "EntityTooSmall", "Your proposed upload smaller than the minimum allowed size.")
+			return
+		}
+		data.Write(part.data)
+		sum, _ := hex.DecodeString(part.etag)
+		sums = append(sums, sum...)
+	}
+	delete(s.uploads, r.URL.Query().Get("uploadId"))
+	s.mu.Unlock()
+
+	obj := newOSSObject(upload.request, data.Bytes())
+	// the etag of a multipart object is the md5 of the md5 of the parts and the number of parts
+	sum := md5.Sum(sums)
+	obj.etag = fmt.Sprintf("%X-%d", sum[:], len(input.Parts))
+	s.store.put(bucket, key, obj)
+
+	w.Header().Set(ossCRC64, strconv.FormatUint(obj.crc64, 10))
+	s.writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Bucket: bucket, Key: key, ETag: ossETag(obj.etag)})
+}
+
+func (s *OSSServer) abortMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	delete(s.uploads, r.URL.Query().Get("uploadId"))
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,171 @@
+package awostest
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shimohq/awos/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSSServer_Conformance(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+
+	t.Run("Bucket", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) awos.Client {
+			client, err := awos.New(server.Options("test"))
+			require.NoError(t, err)
+			return client
+		})
+	})
+	t.Run("Shards", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) awos.Client {
+			options := server.Options("test")
+			options.Shards = []string{"0123456789", "abcdefghijklm", "nopqrstuvwxyz"}
+			client, err := awos.New(options)
+			require.NoError(t, err)
+			return client
+		})
+	})
+}
+
+func TestOSSServer_Decompressor(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	testDecompressor(t, server.Options("test"))
+}
+
+func TestOSSServer_Error(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+
+	_, err = client.Get("missing")
+	var awosErr *awos.Error
+	require.True(t, errors.As(err, &awosErr))
+	assert.Equal(t, http.StatusNotFound, awosErr.StatusCode)
+	assert.Equal(t, "NoSuchKey", awosErr.Code)
+	assert.NotEmpty(t, awosErr.RequestID)
+
+	// the error of HEAD is in the x-oss-err header
+	_, err = client.Head("missing", nil)
+	require.True(t, errors.As(err, &awosErr))
+	assert.Equal(t, "NoSuchKey", awosErr.Code)
+	ok, err := client.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	options := server.Options("test")
+	options.AccessKeySecret = "wrong"
+	client, err = awos.New(options)
+	require.NoError(t, err)
+	err = client.Put("key", strings.NewReader("content"), nil)
+	assert.True(t, errors.Is(err, awos.ErrAccessDenied), "%v", err)
+	require.True(t, errors.As(err, &awosErr))
+	assert.Equal(t, "SignatureDoesNotMatch", awosErr.Code)
+}
+
+func TestOSSServer_Meta(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+	require.NoError(t, client.Put("key", strings.NewReader("content"), map[string]string{"Test-Key": "value"}))
+
+	// the metadata are looked up by the header names, the standard headers first
+	meta, err := client.Head("key", []string{"test-key", "Test-Key", "content-length", "x-oss-hash-crc64ecma", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"test-key":             "value",
+		"Test-Key":             "value",
+		"content-length":       "7",
+		"x-oss-hash-crc64ecma": strconv.FormatUint(crc64.Checksum([]byte("content"), crc64Table), 10),
+		"missing":              "",
+	}, meta)
+}
+
+func TestOSSServer_CRC(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+	require.NoError(t, client.Put("key", strings.NewReader("content"), nil))
+
+	res, err := client.Get("key", awos.EnableCRCValidation())
+	assert.NoError(t, err)
+	assert.Equal(t, "content", res)
+
+	// the content changes behind the stored crc64
+	obj, ok := server.store.get("test", "key")
+	require.True(t, ok)
+	obj.data = []byte("CONTENT")
+	_, err = client.Get("key", awos.EnableCRCValidation())
+	assert.Error(t, err)
+	res, err = client.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "CONTENT", res)
+	assert.Error(t, client.Download("key", &WriterAt{}))
+}
+
+func TestOSSServer_SignURL(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+	require.NoError(t, client.Put("dir/a key", strings.NewReader("content"), nil))
+
+	signed, err := client.SignURL("dir/a key", 60)
+	require.NoError(t, err)
+	res, err := http.Get(signed)
+	require.NoError(t, err)
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "content", string(data))
+
+	res, err = http.Get(strings.Replace(signed, "a%20key", "b%20key", 1))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("Expires", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+	u.RawQuery = query.Encode()
+	res, err = http.Get(u.String())
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestOSSServer_Multipart(t *testing.T) {
+	server := NewOSSServer()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), int(awos.MinPartSize*2+100)/16)
+	err = client.Upload("large", bytes.NewReader(content), map[string]string{"test-key": "value"},
+		awos.UploadWithPartSize(awos.MinPartSize), awos.UploadWithConcurrency(2))
+	require.NoError(t, err)
+
+	meta, err := client.Head("large", []string{"test-key"})
+	require.NoError(t, err)
+	assert.Equal(t, "value", meta["test-key"])
+
+	w := &WriterAt{}
+	require.NoError(t, client.Download("large", w, awos.DownloadWithPartSize(awos.MinPartSize), awos.DownloadWithConcurrency(3)))
+	assert.Equal(t, content, w.Bytes())
+}
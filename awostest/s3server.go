@@ -0,0 +1,471 @@
+package awostest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shimohq/awos/v3"
+)
+
+const (
+	s3MetaPrefix = "x-amz-meta-"
+	s3XMLNS      = "http://s3.amazonaws.com/doc/2006-03-01/"
+	// s3MinPartSize is the minimum size of the parts except the last one
+	s3MinPartSize = 5 << 20
+)
+
+// S3Server is an httptest.Server speaking enough of the S3 REST API for *awos.S3 with S3ForcePathStyle:
+// GetObject, PutObject, CopyObject, HeadObject, DeleteObject, DeleteObjects, ListObjects, ListObjectsV2
+// and the multipart uploads. Every bucket exists and is empty at first.
+// The requests and presigned urls are checked by signature version 4 with AccessKeyID and AccessKeySecret.
+type S3Server struct {
+	*httptest.Server
+	AccessKeyID     string
+	AccessKeySecret string
+	Region          string
+
+	store     *store
+	requestID uint64
+
+	mu       sync.Mutex
+	uploads  map[string]*multipartUpload
+	uploadID uint64
+}
+
+// NewS3Server starts an S3Server, call Close when done
+func NewS3Server() *S3Server {
+	s := &S3Server{
+		AccessKeyID:     "awostest",
+		AccessKeySecret: "awostest-secret",
+		Region:          "us-east-1",
+		store:           newStore(),
+		uploads:         make(map[string]*multipartUpload),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Options returns the Options of a client of the bucket on the server
+func (s *S3Server) Options(bucket string) *awos.Options {
+	return &awos.Options{
+		StorageType:      awos.StorageTypeS3,
+		AccessKeyID:      s.AccessKeyID,
+		AccessKeySecret:  s.AccessKeySecret,
+		Endpoint:         s.URL,
+		Bucket:           bucket,
+		Region:           s.Region,
+		S3ForcePathStyle: true,
+	}
+}
+
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Key       string   `xml:"Key,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func (s *S3Server) writeError(w http.ResponseWriter, r *http.Request, status int, code string, message string, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	// the response of HEAD has no body, the sdk takes the code from the status
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message, Key: key, RequestID: w.Header().Get("X-Amz-Request-Id")})
+}
+
+func (s *S3Server) writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", fmt.Sprintf("%016X", atomic.AddUint64(&s.requestID, 1)))
+
+	if err := verifySigV4(r, s.AccessKeyID, s.AccessKeySecret); err != nil {
+		s.writeError(w, r, http.StatusForbidden, err.Error(), "the signature of the request is invalid", "")
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error(), "")
+		return
+	}
+	if hash := r.Header.Get(sigV4ContentHash); hash != "" && hash != unsignedPayload && hash != sha256Hex(body) {
+		s.writeError(w, r, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "the content hash does not match", "")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	if bucket == "" {
+		s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", "ListBuckets is not supported", "")
+		return
+	}
+	query := r.URL.Query()
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			s.listObjectsV2(w, r, bucket)
+		case r.Method == http.MethodGet:
+			s.listObjects(w, r, bucket)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			s.deleteObjects(w, r, bucket, body)
+		default:
+			s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", r.Method+" of the bucket is not supported", "")
+		}
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.createMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.completeMultipartUpload(w, r, bucket, key, body)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploadPart(w, r, bucket, key, body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		s.abortMultipartUpload(w, r)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		obj := newObject(r, s3MetaPrefix, body)
+		s.store.put(bucket, key, obj)
+		w.Header().Set("ETag", `"`+obj.etag+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.store.delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, r, http.StatusNotImplemented, "NotImplemented", r.Method+" of the object is not supported", key)
+	}
+}
+
+func (s *S3Server) getObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	obj, ok := s.store.get(bucket, key)
+	if !ok {
+		s.writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.", key)
+		return
+	}
+	obj.writeHeaders(w, s3MetaPrefix)
+	query := r.URL.Query()
+	if v := query.Get("response-content-type"); v != "" {
+		w.Header().Set("Content-Type", v)
+	}
+	if v := query.Get("response-content-encoding"); v != "" {
+		w.Header().Set("Content-Encoding", v)
+	}
+
+	data := obj.data
+	status := http.StatusOK
+	if header := r.Header.Get("Range"); header != "" {
+		size := int64(len(obj.data))
+		byteRange, ok, satisfiable := parseRange(header, size)
+		if ok && !satisfiable {
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			s.writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable", key)
+			return
+		}
+		if ok {
+			data = obj.data[byteRange.start : byteRange.end+1]
+			status = http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", byteRange.start, byteRange.end, size))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+func (s *S3Server) copyObject(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	source, err := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+	parts := strings.SplitN(source, "/", 2)
+	if err != nil || len(parts) != 2 {
+		s.writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid x-amz-copy-source", key)
+		return
+	}
+	obj, ok := s.store.get(parts[0], parts[1])
+	if !ok {
+		s.writeError(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.", parts[1])
+		return
+	}
+	copied := *obj
+	copied.lastModified = time.Now().UTC()
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		copied = *newObject(r, s3MetaPrefix, obj.data)
+	}
+	s.store.put(bucket, key, &copied)
+	s.writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{ETag: `"` + copied.etag + `"`, LastModified: copied.lastModified.Format(time.RFC3339)})
+}
+
+type s3Contents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// s3Listing returns the contents and common prefixes of a page
+func s3Listing(items []listed) ([]s3Contents, []s3CommonPrefix) {
+	contents := make([]s3Contents, 0)
+	prefixes := make([]s3CommonPrefix, 0)
+	for _, item := range items {
+		if item.prefix {
+			prefixes = append(prefixes, s3CommonPrefix{Prefix: item.key})
+			continue
+		}
+		contents = append(contents, s3Contents{
+			Key:          item.key,
+			LastModified: item.object.lastModified.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"` + item.object.etag + `"`,
+			Size:         len(item.object.data),
+			StorageClass: "STANDARD",
+		})
+	}
+	return contents, prefixes
+}
+
+func maxKeys(query url.Values) int {
+	n, err := strconv.Atoi(query.Get("max-keys"))
+	if err != nil || n <= 0 || n > 1000 {
+		return 1000
+	}
+	return n
+}
+
+func (s *S3Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	prefix, marker, delimiter := query.Get("prefix"), query.Get("marker"), query.Get("delimiter")
+	items, truncated := s.store.list(bucket, prefix, marker, delimiter, maxKeys(query))
+	contents, prefixes := s3Listing(items)
+	res := struct {
+		XMLName        xml.Name         `xml:"ListBucketResult"`
+		XMLNS          string           `xml:"xmlns,attr"`
+		Name           string           `xml:"Name"`
+		Prefix         string           `xml:"Prefix"`
+		Marker         string           `xml:"Marker"`
+		NextMarker     string           `xml:"NextMarker,omitempty"`
+		MaxKeys        int              `xml:"MaxKeys"`
+		Delimiter      string           `xml:"Delimiter,omitempty"`
+		IsTruncated    bool             `xml:"IsTruncated"`
+		Contents       []s3Contents     `xml:"Contents"`
+		CommonPrefixes []s3CommonPrefix `xml:"CommonPrefixes"`
+	}{XMLNS: s3XMLNS, Name: bucket, Prefix: prefix, Marker: marker, MaxKeys: maxKeys(query), Delimiter: delimiter,
+		IsTruncated: truncated, Contents: contents, CommonPrefixes: prefixes}
+	// like s3, NextMarker is only returned with a delimiter
+	if truncated && delimiter != "" {
+		res.NextMarker = items[len(items)-1].key
+	}
+	s.writeXML(w, res)
+}
+
+func (s *S3Server) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	after := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := hex.DecodeString(token)
+		if err != nil {
+			s.writeError(w, r, http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect", "")
+			return
+		}
+		after = string(decoded)
+	}
+	items, truncated := s.store.list(bucket, prefix, after, delimiter, maxKeys(query))
+	contents, prefixes := s3Listing(items)
+	res := struct {
+		XMLName               xml.Name         `xml:"ListBucketResult"`
+		XMLNS                 string           `xml:"xmlns,attr"`
+		Name                  string           `xml:"Name"`
+		Prefix                string           `xml:"Prefix"`
+		StartAfter            string           `xml:"StartAfter,omitempty"`
+		ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+		KeyCount              int              `xml:"KeyCount"`
+		MaxKeys               int              `xml:"MaxKeys"`
+		Delimiter             string           `xml:"Delimiter,omitempty"`
+		IsTruncated           bool             `xml:"IsTruncated"`
+		Contents              []s3Contents     `xml:"Contents"`
+		CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+	}{XMLNS: s3XMLNS, Name: bucket, Prefix: prefix, StartAfter: query.Get("start-after"), ContinuationToken: token,
+		KeyCount: len(items), MaxKeys: maxKeys(query), Delimiter: delimiter, IsTruncated: truncated,
+		Contents: contents, CommonPrefixes: prefixes}
+	// the token is opaque to the clients
+	if truncated {
+		res.NextContinuationToken = hex.EncodeToString([]byte(items[len(items)-1].key))
+	}
+	s.writeXML(w, res)
+}
+
+func (s *S3Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	var input struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &input); err != nil {
+		s.writeError(w, r, http.StatusBadRequest, "MalformedXML", err.Error(), "")
+		return
+	}
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	res := struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		XMLNS   string    `xml:"xmlns,attr"`
+		Deleted []deleted `xml:"Deleted"`
+	}{XMLNS: s3XMLNS}
+	for _, v := range input.Objects {
+		s.store.delete(bucket, v.Key)
+		if !input.Quiet {
+			res.Deleted = append(res.Deleted, deleted{Key: v.Key})
+		}
+	}
+	s.writeXML(w, res)
+}
+
+func (s *S3Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	s.mu.Lock()
+	s.uploadID++
+	uploadID := fmt.Sprintf("upload-%d", s.uploadID)
+	s.uploads[uploadID] = &multipartUpload{bucket: bucket, key: key, request: &http.Request{Header: r.Header.Clone()}, parts: make(map[int]*object)}
+	s.mu.Unlock()
+
+	s.writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		XMLNS    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{XMLNS: s3XMLNS, Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (s *S3Server) upload(w http.ResponseWriter, r *http.Request, bucket string, key string) (*multipartUpload, bool) {
+	uploadID := r.URL.Query().Get("uploadId")
+	s.mu.Lock()
+	upload, ok := s.uploads[uploadID]
+	s.mu.Unlock()
+	if !ok || upload.bucket != bucket || upload.key != key {
+		s.writeError(w, r, http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist.", key)
+		return nil, false
+	}
+	return upload, true
+}
+
+func (s *S3Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket string, key string, body []byte) {
+	upload, ok := s.upload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		s.writeError(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000", key)
+		return
+	}
+	part := newObject(r, s3MetaPrefix, body)
+	s.mu.Lock()
+	upload.parts[partNumber] = part
+	s.mu.Unlock()
+	w.Header().Set("ETag", `"`+part.etag+`"`)
+}
+
+func (s *S3Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, body []byte) {
+	upload, ok := s.upload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	var input struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &input); err != nil || len(input.Parts) == 0 {
+		s.writeError(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed", key)
+		return
+	}
+	if !sort.SliceIsSorted(input.Parts, func(i, j int) bool { return input.Parts[i].PartNumber < input.Parts[j].PartNumber }) {
+		s.writeError(w, r, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.", key)
+		return
+	}
+
+	s.mu.Lock()
+	var data bytes.Buffer
+	sums := make([]byte, 0, md5.Size*len(input.Parts))
+	for i, v := range input.Parts {
+		part, ok := upload.parts[v.PartNumber]
+		if !ok || strings.Trim(v.ETag, `"`) != part.etag {
+			s.mu.Unlock()
+			s.writeError(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.", key)
+			return
+		}
+		if i < len(input.Parts)-1 && len(part.data) < s3MinPartSize {
+			s.mu.Unlock()
+			s.writeError(w, r, http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.", key)
+			return
+		}
+		data.Write(part.data)
+		sum, _ := hex.DecodeString(part.etag)
+		sums = append(sums, sum...)
+	}
+	delete(s.uploads, r.URL.Query().Get("uploadId"))
+	s.mu.Unlock()
+
+	obj := newObject(upload.request, s3MetaPrefix, data.Bytes())
+	// the etag of a multipart object is the md5 of the md5 of the parts and the number of parts
+	sum := md5.Sum(sums)
+	obj.etag = fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(input.Parts))
+	s.store.put(bucket, key, obj)
+
+	s.writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{XMLNS: s3XMLNS, Bucket: bucket, Key: key, ETag: `"` + obj.etag + `"`})
+}
+
+func (s *S3Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	delete(s.uploads, r.URL.Query().Get("uploadId"))
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package awostest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shimohq/awos/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Server_Conformance(t *testing.T) {
+	server := NewS3Server()
+	defer server.Close()
+
+	t.Run("Bucket", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) awos.Client {
+			client, err := awos.New(server.Options("test"))
+			require.NoError(t, err)
+			return client
+		})
+	})
+	t.Run("Shards", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) awos.Client {
+			options := server.Options("test")
+			options.Shards = []string{"0123456789", "abcdefghijklm", "nopqrstuvwxyz"}
+			client, err := awos.New(options)
+			require.NoError(t, err)
+			return client
+		})
+	})
+}
+
+func TestS3Server_Decompressor(t *testing.T) {
+	server := NewS3Server()
+	defer server.Close()
+	testDecompressor(t, server.Options("test"))
+}
+
+func TestS3Server_Error(t *testing.T) {
+	server := NewS3Server()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+
+	_, err = client.Get("missing")
+	var awosErr *awos.Error
+	require.True(t, errors.As(err, &awosErr))
+	assert.Equal(t, http.StatusNotFound, awosErr.StatusCode)
+	assert.Equal(t, "NoSuchKey", awosErr.Code)
+	assert.NotEmpty(t, awosErr.RequestID)
+
+	options := server.Options("test")
+	options.AccessKeySecret = "wrong"
+	client, err = awos.New(options)
+	require.NoError(t, err)
+	err = client.Put("key", strings.NewReader("content"), nil)
+	assert.True(t, errors.Is(err, awos.ErrAccessDenied), "%v", err)
+	require.True(t, errors.As(err, &awosErr))
+	assert.Equal(t, "SignatureDoesNotMatch", awosErr.Code)
+}
+
+func TestS3Server_SignURL(t *testing.T) {
+	server := NewS3Server()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+	require.NoError(t, client.Put("dir/a key", strings.NewReader("content"), nil))
+
+	signed, err := client.SignURL("dir/a key", 60)
+	require.NoError(t, err)
+	res, err := http.Get(signed)
+	require.NoError(t, err)
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "content", string(data))
+
+	res, err = http.Get(strings.Replace(signed, "a%20key", "b%20key", 1))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res, err = http.Get(strings.Replace(signed, "X-Amz-Expires=60", "X-Amz-Expires=0", 1))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestS3Server_Multipart(t *testing.T) {
+	server := NewS3Server()
+	defer server.Close()
+	client, err := awos.New(server.Options("test"))
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), int(awos.MinPartSize*2+100)/16)
+	err = client.Upload("large", bytes.NewReader(content), map[string]string{"test-key": "value"},
+		awos.UploadWithPartSize(awos.MinPartSize), awos.UploadWithConcurrency(2))
+	require.NoError(t, err)
+
+	meta, err := client.Head("large", []string{"test-key"})
+	require.NoError(t, err)
+	assert.Equal(t, "value", meta["test-key"])
+
+	w := &WriterAt{}
+	require.NoError(t, client.Download("large", w, awos.DownloadWithPartSize(awos.MinPartSize), awos.DownloadWithConcurrency(3)))
+	assert.Equal(t, content, w.Bytes())
+}
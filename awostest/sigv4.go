@@ -0,0 +1,183 @@
+package awostest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	sigV4ContentHash = "X-Amz-Content-Sha256"
+)
+
+var (
+	errInvalidAccessKeyID = errors.New("InvalidAccessKeyId")
+	errSignatureMismatch  = errors.New("SignatureDoesNotMatch")
+	errRequestExpired     = errors.New("AccessDenied")
+	errMissingSignature   = errors.New("AccessDenied")
+)
+
+// sigV4 are the parts of the signature of a request, from the Authorization header or the query of a presigned url
+type sigV4 struct {
+	accessKeyID   string
+	scope         string
+	date          string
+	signedHeaders []string
+	signature     string
+	// presigned the signature is in the query
+	presigned bool
+	expires   time.Duration
+}
+
+func parseSigV4(r *http.Request) (*sigV4, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") == sigV4Algorithm {
+		credential := strings.SplitN(query.Get("X-Amz-Credential"), "/", 2)
+		if len(credential) != 2 {
+			return nil, errMissingSignature
+		}
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil {
+			return nil, errMissingSignature
+		}
+		return &sigV4{
+			accessKeyID:   credential[0],
+			scope:         credential[1],
+			date:          query.Get("X-Amz-Date"),
+			signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+			signature:     query.Get("X-Amz-Signature"),
+			presigned:     true,
+			expires:       time.Duration(expires) * time.Second,
+		}, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+		return nil, errMissingSignature
+	}
+	sig := &sigV4{date: r.Header.Get("X-Amz-Date")}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Credential":
+			credential := strings.SplitN(kv[1], "/", 2)
+			if len(credential) == 2 {
+				sig.accessKeyID, sig.scope = credential[0], credential[1]
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			sig.signature = kv[1]
+		}
+	}
+	if sig.accessKeyID == "" || sig.signature == "" {
+		return nil, errMissingSignature
+	}
+	return sig, nil
+}
+
+// verifySigV4 checks the signature of the request by the secret of accessKeyID, the payload is checked by the caller
+func verifySigV4(r *http.Request, accessKeyID string, secret string) error {
+	sig, err := parseSigV4(r)
+	if err != nil {
+		return err
+	}
+	if sig.accessKeyID != accessKeyID {
+		return errInvalidAccessKeyID
+	}
+	if sig.presigned {
+		signedAt, err := time.Parse(sigV4TimeFormat, sig.date)
+		if err != nil || time.Now().After(signedAt.Add(sig.expires)) {
+			return errRequestExpired
+		}
+	}
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		sig.date,
+		sig.scope,
+		sha256Hex([]byte(canonicalRequest(r, sig))),
+	}, "\n")
+	// the scope is date/region/service/aws4_request
+	scope := strings.Split(sig.scope, "/")
+	if len(scope) != 4 {
+		return errSignatureMismatch
+	}
+	key := []byte("AWS4" + secret)
+	for _, v := range scope {
+		key = hmacSHA256(key, v)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return errSignatureMismatch
+	}
+	return nil
+}
+
+func canonicalRequest(r *http.Request, sig *sigV4) string {
+	// the path as sent, s3 does not escape it again
+	path := r.RequestURI
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	query := r.URL.Query()
+	query.Del("X-Amz-Signature")
+	canonicalQuery := strings.Replace(query.Encode(), "+", "%20", -1)
+
+	headers := make([]string, 0, len(sig.signedHeaders))
+	for _, name := range sig.signedHeaders {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			values := r.Header.Values(name)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		headers = append(headers, name+":"+value)
+	}
+	sort.Strings(headers)
+
+	payload := r.Header.Get(sigV4ContentHash)
+	if sig.presigned {
+		payload = query.Get(sigV4ContentHash)
+		if payload == "" {
+			payload = unsignedPayload
+		}
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		path,
+		canonicalQuery,
+		strings.Join(headers, "\n") + "\n",
+		strings.Join(sig.signedHeaders, ";"),
+		payload,
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package awostest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash/crc64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// object stored by the fake servers
+type object struct {
+	data []byte
+	// meta the user metadata with the lowercase keys
+	meta    map[string]string
+	headers http.Header
+	// etag without the quotes
+	etag string
+	// crc64 is the CRC-64/ECMA checksum of data, as computed by oss
+	crc64        uint64
+	lastModified time.Time
+}
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// contentHeaders are the standard headers stored with the objects
+var contentHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Cache-Control", "Expires"}
+
+// newObject returns the object of the request, the user metadata are the headers beginning with metaPrefix
+func newObject(r *http.Request, metaPrefix string, data []byte) *object {
+	obj := &object{data: data, meta: make(map[string]string), headers: make(http.Header), lastModified: time.Now().UTC()}
+	for name, values := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), metaPrefix) && len(values) > 0 {
+			obj.meta[strings.ToLower(name[len(metaPrefix):])] = values[0]
+		}
+	}
+	for _, name := range contentHeaders {
+		if v := r.Header.Get(name); v != "" {
+			obj.headers.Set(name, v)
+		}
+	}
+	if obj.headers.Get("Content-Type") == "" {
+		obj.headers.Set("Content-Type", "binary/octet-stream")
+	}
+	sum := md5.Sum(data)
+	obj.etag = hex.EncodeToString(sum[:])
+	obj.crc64 = crc64.Checksum(data, crc64Table)
+	return obj
+}
+
+// writeHeaders writes the headers of the object to the response, the user metadata are prefixed by metaPrefix
+func (obj *object) writeHeaders(w http.ResponseWriter, metaPrefix string) {
+	header := w.Header()
+	for k, v := range obj.meta {
+		header.Set(metaPrefix+k, v)
+	}
+	for k, v := range obj.headers {
+		header[k] = v
+	}
+	header.Set("ETag", `"`+obj.etag+`"`)
+	header.Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	header.Set("Content-Length", strconv.Itoa(len(obj.data)))
+}
+
+// multipartUpload is an upload in progress
+type multipartUpload struct {
+	bucket string
+	key    string
+	// request of the initiating request, only its header with the metadata and content headers of the object is kept
+	request *http.Request
+	parts   map[int]*object
+}
+
+// store keeps the objects of the buckets, every bucket exists
+type store struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*object
+}
+
+func newStore() *store {
+	return &store{buckets: make(map[string]map[string]*object)}
+}
+
+func (s *store) get(bucket string, key string) (*object, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.buckets[bucket][key]
+	return obj, ok
+}
+
+func (s *store) put(bucket string, key string, obj *object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]*object)
+	}
+	s.buckets[bucket][key] = obj
+}
+
+func (s *store) delete(bucket string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets[bucket], key)
+}
+
+// listed is a key or a common prefix of a listing
+type listed struct {
+	key    string
+	object *object
+	// prefix is set for a common prefix
+	prefix bool
+}
+
+// list returns at most maxKeys keys and common prefixes after the key after, in lexical order,
+// the keys under after are skipped too if after is a common prefix
+func (s *store) list(bucket string, prefix string, after string, delimiter string, maxKeys int) ([]listed, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	afterPrefix := delimiter != "" && len(after) > len(prefix) && strings.HasSuffix(after, delimiter)
+	keys := make([]string, 0)
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) && key > after && !(afterPrefix && strings.HasPrefix(key, after)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	res := make([]listed, 0)
+	for _, key := range keys {
+		item := listed{key: key, object: s.buckets[bucket][key]}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				item = listed{key: key[:len(prefix)+i+len(delimiter)], prefix: true}
+			}
+		}
+		if item.prefix && len(res) > 0 && res[len(res)-1].key == item.key {
+			continue
+		}
+		if len(res) == maxKeys {
+			return res, true
+		}
+		res = append(res, item)
+	}
+	return res, false
+}
+
+// byteRange is a satisfiable range of the Range header
+type byteRange struct {
+	start, end int64
+}
+
+// parseRange parses "bytes=start-end" of an object of size, ok is false if the header is not a single range,
+// satisfiable is false if the range starts after the end of the object
+func parseRange(header string, size int64) (r byteRange, ok bool, satisfiable bool) {
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return r, false, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return r, false, false
+	}
+	if parts[0] == "" {
+		// the suffix range, the last n bytes
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 {
+			return r, false, false
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{start: size - n, end: size - 1}, true, size > 0
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 {
+		return r, false, false
+	}
+	end := size - 1
+	if parts[1] != "" {
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || end < start {
+			return r, false, false
+		}
+	}
+	if start >= size {
+		return r, true, false
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return byteRange{start: start, end: end}, true, true
+}
@@ -2,34 +2,51 @@ package awos
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/avast/retry-go"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/golang/snappy"
 )
 
 var _ Client = (*S3)(nil)
+var _ BucketClient = (*S3)(nil)
+
+// s3CopyObjectLimit is the max size of an object copied by a single CopyObject
+const s3CopyObjectLimit = int64(5 << 30)
 
 type S3 struct {
+	// ShardsBucket shard => bucket name, keyed by the last character of the key when ShardRouter is nil
 	ShardsBucket map[string]string
+	ShardRouter  ShardRouter
 	BucketName   string
 	Client       *s3.S3
 	compressor   Compressor
-	cfg          *config
+	cfg          *Config
+	retryPolicy  *RetryPolicy
+	interceptors []interceptor
 }
 
 func (a *S3) getBucket(key string) (string, error) {
 	if a.ShardsBucket != nil && len(a.ShardsBucket) > 0 {
-		keyLength := len(key)
-		bucketName := a.ShardsBucket[strings.ToLower(key[keyLength-1:keyLength])]
+		shard, err := routeShard(a.ShardRouter, key)
+		if err != nil {
+			return "", err
+		}
+		bucketName := a.ShardsBucket[shard]
 		if bucketName == "" {
 			return "", errors.New("shards can't find bucket")
 		}
@@ -40,56 +57,42 @@ func (a *S3) getBucket(key string) (string, error) {
 
 // don't forget to call the close() method of the io.ReadCloser
 func (a *S3) GetAsReader(key string, options ...GetOptions) (io.ReadCloser, error) {
-	bucketName, err := a.getBucket(key)
-	if err != nil {
-		return nil, err
-	}
-
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(key),
-	}
-	setS3Options(options, input)
+	return a.GetAsReaderWithContext(context.Background(), key, options...)
+}
 
-	result, err := a.Client.GetObject(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				return nil, nil
-			}
-		}
+func (a *S3) GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	result, err := a.get(ctx, key, options...)
+	if err != nil || result == nil {
 		return nil, err
 	}
 
-	return result.Body, err
+	return a.cfg.decodeBody(result.Body, aws.StringValue(result.ContentEncoding), getS3GetOptions(options))
 }
 
 // don't forget to call the close() method of the io.ReadCloser
 func (a *S3) GetWithMeta(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
-	bucketName, err := a.getBucket(key)
-	if err != nil {
+	return a.GetWithMetaWithContext(context.Background(), key, attributes, options...)
+}
+
+func (a *S3) GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	result, err := a.get(ctx, key, options...)
+	if err != nil || result == nil {
 		return nil, nil, err
 	}
-	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(key),
-	}
-	setS3Options(options, input)
-	result, err := a.Client.GetObject(input)
+	body, err := a.cfg.decodeBody(result.Body, aws.StringValue(result.ContentEncoding), getS3GetOptions(options))
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				return nil, nil, nil
-			}
-		}
 		return nil, nil, err
 	}
-	return result.Body, getS3Meta(attributes, mergeHttpStandardHeaders(&HeadGetObjectOutputWrapper{
+	return body, getS3Meta(attributes, mergeHttpStandardHeaders(&HeadGetObjectOutputWrapper{
 		getObjectOutput: result,
-	})), err
+	})), nil
 }
 
 func (a *S3) GetWithMetaGZIP(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return a.GetWithMetaGZIPWithContext(context.Background(), key, attributes, options...)
+}
+
+func (a *S3) GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return nil, nil, err
@@ -100,14 +103,17 @@ func (a *S3) GetWithMetaGZIP(key string, attributes []string, options ...GetOpti
 	}
 	setS3Options(options, input)
 
-	req, out := a.Client.GetObjectRequest(input)
-	req.HTTPRequest.Header.Add("Accept-Encoding", "gzip")
-	err = req.Send()
+	var out *s3.GetObjectOutput
+	err = a.do(ctx, "GetObject", bucketName, key, true, func(ctx context.Context) error {
+		var req *request.Request
+		req, out = a.Client.GetObjectRequest(input)
+		req.SetContext(ctx)
+		req.HTTPRequest.Header.Add("Accept-Encoding", "gzip")
+		return req.Send()
+	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				return nil, nil, nil
-			}
+		if a.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil, nil
 		}
 		return nil, nil, err
 	}
@@ -117,7 +123,11 @@ func (a *S3) GetWithMetaGZIP(key string, attributes []string, options ...GetOpti
 }
 
 func (a *S3) Get(key string, options ...GetOptions) (string, error) {
-	data, err := a.GetBytes(key, options...)
+	return a.GetWithContext(context.Background(), key, options...)
+}
+
+func (a *S3) GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := a.GetBytesWithContext(ctx, key, options...)
 	if err != nil {
 		return "", err
 	}
@@ -125,7 +135,11 @@ func (a *S3) Get(key string, options ...GetOptions) (string, error) {
 }
 
 func (a *S3) GetBytes(key string, options ...GetOptions) ([]byte, error) {
-	result, err := a.get(key, options...)
+	return a.GetBytesWithContext(context.Background(), key, options...)
+}
+
+func (a *S3) GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	result, err := a.get(ctx, key, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -133,73 +147,106 @@ func (a *S3) GetBytes(key string, options ...GetOptions) ([]byte, error) {
 		return nil, nil
 	}
 
-	body := result.Body
-	defer func() {
-		if body != nil {
-			body.Close()
-		}
-	}()
+	body, err := a.cfg.decodeBody(result.Body, aws.StringValue(result.ContentEncoding), getS3GetOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
 
 	return ioutil.ReadAll(body)
 }
 
 func (a *S3) Range(key string, offset int64, length int64) (io.ReadCloser, error) {
+	return a.RangeWithContext(context.Background(), key, offset, length)
+}
+
+func (a *S3) RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	bucketName, err := a.getBucket(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	err = a.retryPolicy.do(ctx, true, func(ctx context.Context) error {
+		r, err = a.getRange(ctx, bucketName, key, offset, length)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getRange sends a single ranged GetObject, retries are up to the caller
+func (a *S3) getRange(ctx context.Context, bucketName string, key string, offset int64, length int64) (io.ReadCloser, error) {
 	readRange := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(a.BucketName),
+		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 		Range:  &readRange,
 	}
-	r, err := a.Client.GetObject(input)
+	var r *s3.GetObjectOutput
+	err := a.invoke(ctx, "GetObject", bucketName, key, func(ctx context.Context) error {
+		var err error
+		r, err = a.Client.GetObjectWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	return r.Body, nil
 }
 
-func (a *S3) GetAndDecompress(key string) (string, error) {
-	result, err := a.get(key)
+// Download fetches the object by concurrent ranges and writes them to w, e.g. an *os.File.
+// The content is checked against the ETag unless it was uploaded by multipart.
+func (a *S3) Download(key string, w io.WriterAt, options ...DownloadOptions) error {
+	return a.DownloadWithContext(context.Background(), key, w, options...)
+}
+
+func (a *S3) DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error {
+	bucketName, err := a.getBucket(key)
 	if err != nil {
-		return "", err
-	}
-	if result == nil {
-		return "", nil
+		return err
 	}
 
-	body := result.Body
-	defer func() {
-		if body != nil {
-			body.Close()
-		}
-	}()
-
-	compressor := result.Metadata["Compressor"]
-	if compressor != nil {
-		if *compressor != "snappy" {
-			return "", errors.New("GetAndDecompress only supports snappy for now, got " + *compressor)
-		}
+	var result *s3.HeadObjectOutput
+	err = a.do(ctx, "HeadObject", bucketName, key, true, func(ctx context.Context) error {
+		result, err = a.Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		rawBytes, err := ioutil.ReadAll(body)
-		if err != nil {
-			return "", err
+	downloadOpts := getDownloadOptions(options)
+	object := &downloadObject{size: aws.Int64Value(result.ContentLength)}
+	// the ETag of an object uploaded by multipart is not the md5 of the content
+	etag := strings.Trim(aws.StringValue(result.ETag), `"`)
+	if !downloadOpts.noVerify && len(etag) == md5.Size*2 {
+		object.hash = md5.New()
+		object.checksum = strings.ToLower(etag)
+		object.sum = func(h hash.Hash) string {
+			return hex.EncodeToString(h.Sum(nil))
 		}
+	}
+	return downloadRanges(ctx, object, w, downloadOpts, a.retryPolicy, func(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+		return a.getRange(ctx, bucketName, key, offset, length)
+	})
+}
 
-		decodedBytes, err := snappy.Decode(nil, rawBytes)
-		if err != nil {
-			if errors.Is(err, snappy.ErrCorrupt) {
-				reader := snappy.NewReader(bytes.NewReader(rawBytes))
-				data, err := ioutil.ReadAll(reader)
-				if err != nil {
-					return "", err
-				}
-
-				return string(data), nil
-			}
-			return "", err
-		}
+func (a *S3) GetAndDecompress(key string) (string, error) {
+	return a.GetAndDecompressWithContext(context.Background(), key)
+}
 
-		return string(decodedBytes), nil
+func (a *S3) GetAndDecompressWithContext(ctx context.Context, key string) (string, error) {
+	body, err := a.GetAndDecompressAsReaderWithContext(ctx, key)
+	if err != nil || body == nil {
+		return "", err
 	}
+	defer body.Close()
 
 	data, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -209,15 +256,28 @@ func (a *S3) GetAndDecompress(key string) (string, error) {
 }
 
 func (a *S3) GetAndDecompressAsReader(key string) (io.ReadCloser, error) {
-	result, err := a.GetAndDecompress(key)
-	if err != nil {
+	return a.GetAndDecompressAsReaderWithContext(context.Background(), key)
+}
+
+func (a *S3) GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	result, err := a.get(ctx, key, GetRaw())
+	if err != nil || result == nil {
 		return nil, err
 	}
 
-	return ioutil.NopCloser(strings.NewReader(result)), nil
+	return decompressBody(result.Body, aws.StringValue(result.Metadata["Compressor"]), aws.StringValue(result.ContentEncoding))
 }
 
 func (a *S3) Put(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return a.PutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (a *S3) PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return a.putObject(ctx, key, reader, meta, a.compressor, options...)
+}
+
+// putObject puts the object, the body is compressed by compressor if it isn't nil
+func (a *S3) putObject(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, compressor Compressor, options ...PutOptions) error {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return err
@@ -247,40 +307,42 @@ func (a *S3) Put(key string, reader io.ReadSeeker, meta map[string]string, optio
 	if putOptions.expires != nil {
 		input.Expires = putOptions.expires
 	}
-	if a.compressor != nil {
-		wrapReader, i, err := GetReaderLength(input.Body)
+	if compressor != nil && reader != nil {
+		body, err := compressBody(compressor, reader, a.cfg.CompressLimit)
 		if err != nil {
 			return err
 		}
-		if i < a.cfg.CompressLimit {
-			input.Body = wrapReader
-		} else {
-			// var clen int64 // 压缩后的数据长度
-			input.Body, _, err = a.compressor.Compress(wrapReader)
-			if err != nil {
-				return err
-			}
-			encoding := a.compressor.ContentEncoding()
+		defer body.Close()
+		input.Body = body
+		if body.compressed {
+			encoding := compressor.ContentEncoding()
 			input.ContentEncoding = &encoding
-			// input.SetContentLength(clen)
-			// logger.Info("gzipCompressSuccess", "length", clen, "meta", meta)
+			a.cfg.observeCompression(StorageTypeS3, bucketName, "PutObject", encoding, body.rawSize, body.size)
 		}
 	}
-	err = retry.Do(func() error {
-		_, err := a.Client.PutObject(input)
-		if err != nil && reader != nil {
+	err = a.do(ctx, "PutObject", bucketName, key, true, func(ctx context.Context) error {
+		_, err := a.Client.PutObjectWithContext(ctx, input)
+		if err != nil && input.Body != nil {
 			// Reset the body reader after the request since at this point it's already read
 			// Note that it's safe to ignore the error here since the 0,0 position is always valid
-			_, _ = reader.Seek(0, 0)
+			_, _ = input.Body.Seek(0, 0)
 		}
 		return err
-	}, retry.Attempts(3), retry.Delay(1*time.Second))
+	})
 
 	return err
 }
 
 func (a *S3) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
-	data, err := ioutil.ReadAll(reader)
+	return a.CompressAndPutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (a *S3) CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	body, size, rawSize, err := compressForPut(putOptions.compressType, reader)
 	if err != nil {
 		return err
 	}
@@ -288,14 +350,114 @@ func (a *S3) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]st
 		meta = make(map[string]string)
 	}
 
-	encodedBytes := snappy.Encode(nil, data)
+	if bucketName, err := a.getBucket(key); err == nil {
+		a.cfg.observeCompression(StorageTypeS3, bucketName, "PutObject", putOptions.compressType, rawSize, size)
+	}
+
+	meta["Compressor"] = putOptions.compressType
 
-	meta["Compressor"] = "snappy"
+	return a.PutWithContext(ctx, key, body, meta, options...)
+}
 
-	return a.Put(key, bytes.NewReader(encodedBytes), meta, options...)
+// Upload uploads a large object in parts, objects smaller than a single part are sent in one request.
+// Unlike Put, the body is never compressed, whatever its size.
+func (a *S3) Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return a.UploadWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (a *S3) UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	bucketName, err := a.getBucket(key)
+	if err != nil {
+		return err
+	}
+
+	uploadOpts := getUploadOptions(options)
+	first, drained, err := readPart(reader, uploadOpts.partSize)
+	if err != nil {
+		return err
+	}
+	if drained {
+		return a.putObject(ctx, key, bytes.NewReader(first), meta, nil, uploadOpts.putOptions...)
+	}
+
+	putOptions := DefaultPutOptions()
+	for _, opt := range uploadOpts.putOptions {
+		opt(putOptions)
+	}
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(bucketName),
+		Key:                aws.String(key),
+		Metadata:           aws.StringMap(meta),
+		ContentType:        aws.String(putOptions.contentType),
+		ContentEncoding:    putOptions.contentEncoding,
+		ContentDisposition: putOptions.contentDisposition,
+		CacheControl:       putOptions.cacheControl,
+		Expires:            putOptions.expires,
+	}
+	var created *s3.CreateMultipartUploadOutput
+	err = a.do(ctx, "CreateMultipartUpload", bucketName, key, false, func(ctx context.Context) error {
+		created, err = a.Client.CreateMultipartUploadWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	parts := make([]*s3.CompletedPart, 0)
+	err = uploadParts(ctx, first, reader, uploadOpts, a.retryPolicy, func(ctx context.Context, partNumber int, part []byte) error {
+		var result *s3.UploadPartOutput
+		err := a.invoke(ctx, "UploadPart", bucketName, key, func(ctx context.Context) error {
+			var err error
+			result, err = a.Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+				Body:          bytes.NewReader(part),
+				Bucket:        aws.String(bucketName),
+				Key:           aws.String(key),
+				PartNumber:    aws.Int64(int64(partNumber)),
+				UploadId:      created.UploadId,
+				ContentLength: aws.Int64(int64(len(part))),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		parts = append(parts, &s3.CompletedPart{ETag: result.ETag, PartNumber: aws.Int64(int64(partNumber))})
+		mu.Unlock()
+		return nil
+	})
+	if err == nil {
+		sort.Slice(parts, func(i, j int) bool {
+			return *parts[i].PartNumber < *parts[j].PartNumber
+		})
+		err = a.do(ctx, "CompleteMultipartUpload", bucketName, key, false, func(ctx context.Context) error {
+			_, err := a.Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(bucketName),
+				Key:             aws.String(key),
+				UploadId:        created.UploadId,
+				MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+			})
+			return err
+		})
+	}
+	if err != nil {
+		// the context may already be done, abort with a fresh one so that the uploaded parts are released
+		_, _ = a.Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucketName),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		return err
+	}
+	return nil
 }
 
 func (a *S3) Del(key string) error {
+	return a.DelWithContext(context.Background(), key)
+}
+
+func (a *S3) DelWithContext(ctx context.Context, key string) error {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return err
@@ -306,11 +468,17 @@ func (a *S3) Del(key string) error {
 		Key:    aws.String(key),
 	}
 
-	_, err = a.Client.DeleteObject(input)
-	return err
+	return a.do(ctx, "DeleteObject", bucketName, key, true, func(ctx context.Context) error {
+		_, err := a.Client.DeleteObjectWithContext(ctx, input)
+		return err
+	})
 }
 
 func (a *S3) DelMulti(keys []string) error {
+	return a.DelMultiWithContext(context.Background(), keys)
+}
+
+func (a *S3) DelMultiWithContext(ctx context.Context, keys []string) error {
 	bucketsNameKeys := make(map[string][]string)
 	for _, key := range keys {
 		bucketName, err := a.getBucket(key)
@@ -337,7 +505,10 @@ func (a *S3) DelMulti(keys []string) error {
 			},
 		}
 
-		_, err := a.Client.DeleteObjects(input)
+		err := a.do(ctx, "DeleteObjects", bucketName, "", true, func(ctx context.Context) error {
+			_, err := a.Client.DeleteObjectsWithContext(ctx, input)
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -347,6 +518,10 @@ func (a *S3) DelMulti(keys []string) error {
 }
 
 func (a *S3) Head(key string, attributes []string) (map[string]string, error) {
+	return a.HeadWithContext(context.Background(), key, attributes)
+}
+
+func (a *S3) HeadWithContext(ctx context.Context, key string, attributes []string) (map[string]string, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return nil, err
@@ -357,13 +532,14 @@ func (a *S3) Head(key string, attributes []string) (map[string]string, error) {
 		Key:    aws.String(key),
 	}
 
-	result, err := a.Client.HeadObject(input)
-
+	var result *s3.HeadObjectOutput
+	err = a.do(ctx, "HeadObject", bucketName, key, true, func(ctx context.Context) error {
+		result, err = a.Client.HeadObjectWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
-		if aerr, ok := err.(awserr.RequestFailure); ok {
-			if aerr.StatusCode() == 404 {
-				return nil, nil
-			}
+		if a.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -373,6 +549,10 @@ func (a *S3) Head(key string, attributes []string) (map[string]string, error) {
 }
 
 func (a *S3) ListObject(key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return a.ListObjectWithContext(context.Background(), key, prefix, marker, maxKeys, delimiter)
+}
+
+func (a *S3) ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return nil, err
@@ -394,7 +574,11 @@ func (a *S3) ListObject(key string, prefix string, marker string, maxKeys int, d
 		input.Delimiter = aws.String(delimiter)
 	}
 
-	result, err := a.Client.ListObjects(input)
+	var result *s3.ListObjectsOutput
+	err = a.do(ctx, "ListObjects", bucketName, "", true, func(ctx context.Context) error {
+		result, err = a.Client.ListObjectsWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -407,6 +591,74 @@ func (a *S3) ListObject(key string, prefix string, marker string, maxKeys int, d
 	return keys, nil
 }
 
+// List returns a page of objects with their metadata, by ListObjectsV2
+func (a *S3) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	if input.AllShards && len(a.ShardsBucket) > 0 {
+		buckets := make([]string, 0, len(a.ShardsBucket))
+		for _, bucketName := range a.ShardsBucket {
+			buckets = append(buckets, bucketName)
+		}
+		return listShards(ctx, buckets, input, a.list)
+	}
+
+	bucketName, err := a.getBucket(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	return a.list(ctx, bucketName, input)
+}
+
+func (a *S3) list(ctx context.Context, bucketName string, input ListInput) (*ListResult, error) {
+	listInput := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	}
+	if input.Prefix != "" {
+		listInput.Prefix = aws.String(input.Prefix)
+	}
+	if input.Marker != "" {
+		listInput.StartAfter = aws.String(input.Marker)
+	}
+	if input.ContinuationToken != "" {
+		listInput.ContinuationToken = aws.String(input.ContinuationToken)
+	}
+	if input.MaxKeys > 0 {
+		listInput.MaxKeys = aws.Int64(int64(input.MaxKeys))
+	}
+	if input.Delimiter != "" {
+		listInput.Delimiter = aws.String(input.Delimiter)
+	}
+
+	var result *s3.ListObjectsV2Output
+	err := a.do(ctx, "ListObjectsV2", bucketName, "", true, func(ctx context.Context) error {
+		var err error
+		result, err = a.Client.ListObjectsV2WithContext(ctx, listInput)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ListResult{
+		Objects:               make([]ObjectInfo, 0, len(result.Contents)),
+		CommonPrefixes:        make([]string, 0, len(result.CommonPrefixes)),
+		IsTruncated:           aws.BoolValue(result.IsTruncated),
+		NextContinuationToken: aws.StringValue(result.NextContinuationToken),
+	}
+	for _, v := range result.Contents {
+		res.Objects = append(res.Objects, ObjectInfo{
+			Key:          aws.StringValue(v.Key),
+			Size:         aws.Int64Value(v.Size),
+			ETag:         strings.Trim(aws.StringValue(v.ETag), `"`),
+			LastModified: aws.TimeValue(v.LastModified),
+			StorageClass: aws.StringValue(v.StorageClass),
+		})
+	}
+	for _, v := range result.CommonPrefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, aws.StringValue(v.Prefix))
+	}
+	return res, nil
+}
+
 func (a *S3) SignURL(key string, expired int64, options ...SignOptions) (string, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
@@ -428,6 +680,10 @@ func (a *S3) SignURL(key string, expired int64, options ...SignOptions) (string,
 }
 
 func (a *S3) Exists(key string) (bool, error) {
+	return a.ExistsWithContext(context.Background(), key)
+}
+
+func (a *S3) ExistsWithContext(ctx context.Context, key string) (bool, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return false, err
@@ -437,20 +693,159 @@ func (a *S3) Exists(key string) (bool, error) {
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 	}
-	_, err = a.Client.HeadObject(input)
+	err = a.do(ctx, "HeadObject", bucketName, key, true, func(ctx context.Context) error {
+		_, err := a.Client.HeadObjectWithContext(ctx, input)
+		return err
+	})
 	if err == nil {
 		return true, nil
 	}
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// ListBucket is List on the named bucket regardless of the shard routing
+func (a *S3) ListBucket(ctx context.Context, bucket string, input ListInput) (*ListResult, error) {
+	return a.list(ctx, bucket, input)
+}
+
+// StatBucketObject returns the attributes of the object in the named bucket, or nil if it does not exist
+func (a *S3) StatBucketObject(ctx context.Context, bucket string, key string) (*ObjectStat, error) {
+	result, err := a.headBucketObject(ctx, bucket, key)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	stat := &ObjectStat{
+		Size:            aws.Int64Value(result.ContentLength),
+		ETag:            strings.Trim(aws.StringValue(result.ETag), `"`),
+		ContentType:     aws.StringValue(result.ContentType),
+		ContentEncoding: aws.StringValue(result.ContentEncoding),
+		Meta:            make(map[string]string),
+	}
+	for k, v := range result.Metadata {
+		stat.Meta[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return stat, nil
+}
+
+// CopyBetweenBuckets copies the object server-side with its metadata and content headers,
+// objects larger than 5GB are copied by multipart
+func (a *S3) CopyBetweenBuckets(ctx context.Context, srcBucket string, dstBucket string, key string) error {
+	head, err := a.headBucketObject(ctx, srcBucket, key)
+	if err != nil {
+		return err
+	}
+	if head == nil {
+		return fmt.Errorf("copy source %s/%s does not exist", srcBucket, key)
+	}
 
-	if aerr, ok := err.(awserr.RequestFailure); ok {
-		if aerr.StatusCode() == 404 {
-			return false, nil
+	copySource := (&url.URL{Path: srcBucket + "/" + key}).EscapedPath()
+	size := aws.Int64Value(head.ContentLength)
+	if size <= s3CopyObjectLimit {
+		return a.do(ctx, "CopyObject", dstBucket, key, true, func(ctx context.Context) error {
+			_, err := a.Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+				Bucket:     aws.String(dstBucket),
+				Key:        aws.String(key),
+				CopySource: aws.String(copySource),
+			})
+			return err
+		})
+	}
+
+	var created *s3.CreateMultipartUploadOutput
+	err = a.do(ctx, "CreateMultipartUpload", dstBucket, key, false, func(ctx context.Context) error {
+		created, err = a.Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(dstBucket),
+			Key:                aws.String(key),
+			Metadata:           head.Metadata,
+			ContentType:        head.ContentType,
+			ContentEncoding:    head.ContentEncoding,
+			ContentDisposition: head.ContentDisposition,
+			CacheControl:       head.CacheControl,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	parts := make([]*s3.CompletedPart, 0)
+	for offset, partNumber := int64(0), int64(1); offset < size; offset, partNumber = offset+copyPartSize, partNumber+1 {
+		last := offset + copyPartSize - 1
+		if last >= size {
+			last = size - 1
 		}
+		var result *s3.UploadPartCopyOutput
+		err = a.do(ctx, "UploadPartCopy", dstBucket, key, true, func(ctx context.Context) error {
+			result, err = a.Client.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+				Bucket:          aws.String(dstBucket),
+				Key:             aws.String(key),
+				CopySource:      aws.String(copySource),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, last)),
+				PartNumber:      aws.Int64(partNumber),
+				UploadId:        created.UploadId,
+			})
+			return err
+		})
+		if err != nil {
+			break
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: result.CopyPartResult.ETag, PartNumber: aws.Int64(partNumber)})
 	}
-	return false, err
+	if err == nil {
+		err = a.do(ctx, "CompleteMultipartUpload", dstBucket, key, false, func(ctx context.Context) error {
+			_, err := a.Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(dstBucket),
+				Key:             aws.String(key),
+				UploadId:        created.UploadId,
+				MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+			})
+			return err
+		})
+	}
+	if err != nil {
+		_, _ = a.Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		return err
+	}
+	return nil
 }
 
-func (a *S3) get(key string, options ...GetOptions) (*s3.GetObjectOutput, error) {
+// DelFromBucket deletes the object from the named bucket regardless of the shard routing
+func (a *S3) DelFromBucket(ctx context.Context, bucket string, key string) error {
+	return a.do(ctx, "DeleteObject", bucket, key, true, func(ctx context.Context) error {
+		_, err := a.Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+}
+
+func (a *S3) headBucketObject(ctx context.Context, bucket string, key string) (*s3.HeadObjectOutput, error) {
+	var result *s3.HeadObjectOutput
+	err := a.do(ctx, "HeadObject", bucket, key, true, func(ctx context.Context) error {
+		var err error
+		result, err = a.Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *S3) get(ctx context.Context, key string, options ...GetOptions) (*s3.GetObjectOutput, error) {
 	bucketName, err := a.getBucket(key)
 	if err != nil {
 		return nil, err
@@ -461,14 +856,19 @@ func (a *S3) get(key string, options ...GetOptions) (*s3.GetObjectOutput, error)
 		Key:    aws.String(key),
 	}
 	setS3Options(options, input)
+	var reqOpts []request.Option
+	if encoding := a.cfg.acceptEncoding(getS3GetOptions(options)); encoding != "" {
+		reqOpts = append(reqOpts, request.WithSetRequestHeaders(map[string]string{"Accept-Encoding": encoding}))
+	}
 
-	result, err := a.Client.GetObject(input)
-
+	var result *s3.GetObjectOutput
+	err = a.do(ctx, "GetObject", bucketName, key, true, func(ctx context.Context) error {
+		result, err = a.Client.GetObjectWithContext(ctx, input, reqOpts...)
+		return err
+	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				return nil, nil
-			}
+		if a.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -476,6 +876,54 @@ func (a *S3) get(key string, options ...GetOptions) (*s3.GetObjectOutput, error)
 	return result, nil
 }
 
+func (a *S3) notFoundAsNil() bool {
+	return a.cfg != nil && a.cfg.NotFoundAsNil
+}
+
+// do sends a request of op with the retry policy, see invoke
+func (a *S3) do(ctx context.Context, op string, bucket string, key string, idempotent bool, fn func(ctx context.Context) error) error {
+	return a.retryPolicy.do(ctx, idempotent, func(ctx context.Context) error {
+		return a.invoke(ctx, op, bucket, key, fn)
+	})
+}
+
+// invoke sends a single attempt of a request of op through the interceptors, the error is converted to *Error
+func (a *S3) invoke(ctx context.Context, op string, bucket string, key string, fn func(ctx context.Context) error) error {
+	info := &requestInfo{StorageType: StorageTypeS3, Op: op, Bucket: bucket, Shard: a.shardOf(bucket), Key: key}
+	return runInterceptors(ctx, a.interceptors, info, func(err error) error {
+		return a.wrapError(op, bucket, key, err)
+	}, fn)
+}
+
+// shardOf returns the shard of the bucket, "" without shards
+func (a *S3) shardOf(bucket string) string {
+	for shard, name := range a.ShardsBucket {
+		if name == bucket {
+			return shard
+		}
+	}
+	return ""
+}
+
+// wrapError converts the error of the sdk to *Error
+func (a *S3) wrapError(op string, bucket string, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	res := &Error{StorageType: StorageTypeS3, Op: op, Bucket: bucket, Key: key, Err: err}
+	if aerr, ok := err.(awserr.Error); ok {
+		res.Code = aerr.Code()
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok {
+		res.StatusCode = rerr.StatusCode()
+		res.RequestID = rerr.RequestID()
+	}
+	return res
+}
+
 func getS3Meta(attributes []string, metaData map[string]*string) map[string]string {
 	// https://github.com/aws/aws-sdk-go/issues/445
 	// aws 会将 meta 的首字母大写，在这里需要转换下
@@ -489,11 +937,16 @@ func getS3Meta(attributes []string, metaData map[string]*string) map[string]stri
 	return res
 }
 
-func setS3Options(options []GetOptions, getObjectInput *s3.GetObjectInput) {
+func getS3GetOptions(options []GetOptions) *getOptions {
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
 	}
+	return getOpts
+}
+
+func setS3Options(options []GetOptions, getObjectInput *s3.GetObjectInput) {
+	getOpts := getS3GetOptions(options)
 	if getOpts.contentEncoding != nil {
 		getObjectInput.ResponseContentEncoding = getOpts.contentEncoding
 	}
@@ -6,6 +6,7 @@ Put your environment configuration in ".env-aws"
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -24,16 +25,13 @@ const (
 	S3CompressContent = "snappy-contentsnappy-contentsnappy-contentsnappy-content"
 )
 
-var (
-	awsClient Client
-)
-
-func init() {
-	// err := godotenv.Overload(".env-aws")
-	// if err != nil {
-	// 	panic(err)
-	// }
-
+// liveS3Client returns the client of the storage configured by the environment, e.g. StorageType, AccessKeyID and Bucket.
+// The test is skipped without StorageType, so that the other tests run without a live storage.
+func liveS3Client(t *testing.T) Client {
+	t.Helper()
+	if os.Getenv("StorageType") == "" {
+		t.Skip("StorageType is not set, the live s3 tests are skipped")
+	}
 	client, err := New(&Options{
 		StorageType:      os.Getenv("StorageType"),
 		AccessKeyID:      os.Getenv("AccessKeyID"),
@@ -47,15 +45,14 @@ func init() {
 		CompressType:     "gzip",
 		CompressLimit:    0,
 	})
-
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
-
-	awsClient = client
+	return client
 }
 
 func TestS3_Put(t *testing.T) {
+	awsClient := liveS3Client(t)
 	meta := make(map[string]string)
 	meta["head"] = strconv.Itoa(S3ExpectHead)
 	meta["length"] = strconv.Itoa(S3ExpectLength)
@@ -74,6 +71,7 @@ func TestS3_Put(t *testing.T) {
 }
 
 func TestS3_CompressAndPut(t *testing.T) {
+	awsClient := liveS3Client(t)
 	meta := make(map[string]string)
 	meta["head"] = strconv.Itoa(S3ExpectHead)
 	meta["length"] = strconv.Itoa(S3ExpectLength)
@@ -92,6 +90,7 @@ func TestS3_CompressAndPut(t *testing.T) {
 }
 
 func TestS3_Head(t *testing.T) {
+	awsClient := liveS3Client(t)
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head", "Content-Length")
 	var res map[string]string
@@ -128,6 +127,7 @@ func TestS3_Head(t *testing.T) {
 }
 
 func TestS3_Get(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res, err := awsClient.Get(S3Guid)
 	if err != nil || res != S3Content {
 		t.Log("aws get S3Content fail, res:", res, "err:", err)
@@ -147,6 +147,7 @@ func TestS3_Get(t *testing.T) {
 }
 
 func TestS3_GetWithMeta(t *testing.T) {
+	awsClient := liveS3Client(t)
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head")
 	res, meta, err := awsClient.GetWithMeta(S3Guid, attributes)
@@ -168,6 +169,7 @@ func TestS3_GetWithMeta(t *testing.T) {
 
 // compressed content
 func TestS3_GetAndDecompress(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res, err := awsClient.GetAndDecompress(S3CompressGUID)
 	if err != nil || res != S3CompressContent {
 		t.Log("aws get S3 conpressed Content fail, res:", res, "err:", err)
@@ -187,6 +189,7 @@ func TestS3_GetAndDecompress(t *testing.T) {
 
 // non-compressed content
 func TestS3_GetAndDecompress2(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res, err := awsClient.GetAndDecompress(S3Guid)
 	if err != nil || res != S3Content {
 		t.Log("aws get S3Content fail, res:", res, "err:", err)
@@ -205,6 +208,7 @@ func TestS3_GetAndDecompress2(t *testing.T) {
 }
 
 func TestS3_SignURL(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res, err := awsClient.SignURL(S3Guid, 60)
 	if err != nil {
 		t.Log("oss signUrl fail, res:", res, "err:", err)
@@ -213,6 +217,7 @@ func TestS3_SignURL(t *testing.T) {
 }
 
 func TestS3_ListObject(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res, err := awsClient.ListObject(S3Guid, S3Guid[0:4], "", 10, "")
 	if err != nil || len(res) == 0 {
 		t.Log("aws list objects fail, res:", res, "err:", err)
@@ -221,6 +226,7 @@ func TestS3_ListObject(t *testing.T) {
 }
 
 func TestS3_Del(t *testing.T) {
+	awsClient := liveS3Client(t)
 	err := awsClient.Del(S3Guid)
 	if err != nil {
 		t.Log("aws del key fail, err:", err)
@@ -229,8 +235,9 @@ func TestS3_Del(t *testing.T) {
 }
 
 func TestS3_GetNotExist(t *testing.T) {
+	awsClient := liveS3Client(t)
 	res1, err := awsClient.Get(S3Guid + "123")
-	if res1 != "" || err != nil {
+	if res1 != "" || !errors.Is(err, ErrNotFound) {
 		t.Log("aws get not exist key fail, res:", res1, "err:", err)
 		t.Fail()
 	}
@@ -238,13 +245,14 @@ func TestS3_GetNotExist(t *testing.T) {
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head")
 	res2, err := awsClient.Head(S3Guid+"123", attributes)
-	if res2 != nil || err != nil {
+	if res2 != nil || !IsNotFound(err) {
 		t.Log("aws head not exist key fail, res:", res2, "err:", err, err.Error())
 		t.Fail()
 	}
 }
 
 func TestS3_DelMulti(t *testing.T) {
+	awsClient := liveS3Client(t)
 	keys := []string{"aaa", "bbb", "ccc"}
 	for _, key := range keys {
 		awsClient.Put(key, strings.NewReader("2333333"), nil)
@@ -258,7 +266,7 @@ func TestS3_DelMulti(t *testing.T) {
 
 	for _, key := range keys {
 		res, err := awsClient.Get(key)
-		if res != "" || err != nil {
+		if res != "" || !IsNotFound(err) {
 			t.Logf("key:%s should not be exist", key)
 			t.Fail()
 		}
@@ -266,6 +274,7 @@ func TestS3_DelMulti(t *testing.T) {
 }
 
 func TestS3_Range(t *testing.T) {
+	awsClient := liveS3Client(t)
 	meta := make(map[string]string)
 	err := awsClient.Put(guid, strings.NewReader("123456"), meta)
 	if err != nil {
@@ -286,6 +295,7 @@ func TestS3_Range(t *testing.T) {
 }
 
 func TestS3_Exists(t *testing.T) {
+	awsClient := liveS3Client(t)
 	meta := make(map[string]string)
 	err := awsClient.Put(guid, strings.NewReader("123456"), meta)
 	if err != nil {
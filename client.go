@@ -1,17 +1,23 @@
 package awos
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client interface
@@ -32,17 +38,47 @@ type Client interface {
 	CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error
 	Range(key string, offset int64, length int64) (io.ReadCloser, error)
 	Exists(key string) (bool, error)
+	Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error
+	Download(key string, w io.WriterAt, options ...DownloadOptions) error
+
+	ContextClient
+}
+
+// ContextClient is the context-aware variant of Client, the context is passed down to the
+// underlying sdk so that deadlines and cancellations reach oss/s3.
+// SignURL has no variant because it does not send any request.
+type ContextClient interface {
+	GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error)
+	GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error)
+	GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error)
+	GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error)
+	GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error)
+	PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error
+	DelWithContext(ctx context.Context, key string) error
+	DelMultiWithContext(ctx context.Context, keys []string) error
+	HeadWithContext(ctx context.Context, key string, meta []string) (map[string]string, error)
+	ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error)
+	GetAndDecompressWithContext(ctx context.Context, key string) (string, error)
+	GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error)
+	CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error
+	RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error)
+	ExistsWithContext(ctx context.Context, key string) (bool, error)
+	UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error
+	DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error
+	List(ctx context.Context, input ListInput) (*ListResult, error)
 }
 
 // Options for New method
 type Options struct {
-	// Required, value is one of oss/s3, case insensetive
+	// Required, value is one of oss/s3/file, case insensetive
 	StorageType string
-	// Required
+	// Required unless CredentialsProvider is set
 	AccessKeyID string
-	// Required
+	// Required unless CredentialsProvider is set, the secret of the signed urls for file
 	AccessKeySecret string
-	// Required
+	// Optional, the rotated credentials instead of AccessKeyID/AccessKeySecret, e.g. NewAliyunAssumeRoleCredentials
+	CredentialsProvider CredentialsProvider
+	// Required, the root directory for file
 	Endpoint string
 	// Required
 	Bucket string
@@ -50,6 +86,8 @@ type Options struct {
 	// if bucket is 'content', shards is ['abc', 'edf'],
 	// then the last character of the key with a/b/c will automatically use the content-abc bucket, and vice versa
 	Shards []string
+	// Optional, choose the shard of a key instead of the last character, e.g. NewConsistentHashRouter(shards, 0)
+	ShardRouter ShardRouter
 	// Only for s3-like
 	Region string
 	// Only for s3-like, whether to force path style URLs for S3 objects.
@@ -68,6 +106,26 @@ type Options struct {
 	CompressType string
 	// CompressLimit 大于该值之后才压缩 单位字节
 	CompressLimit int
+	// Optional, Get/GetBytes/GetAsReader/GetWithMeta decode the objects by the registered decompressor of their Content-Encoding,
+	// whatever the writer was configured with, use GetRaw to get the stored content
+	EnableDecompressor bool
+	// NotFoundAsNil keeps the behavior of v3.0 for missing objects: Get returns "", nil,
+	// GetBytes/GetAsReader/GetWithMeta/Head return nil, nil instead of ErrNotFound
+	NotFoundAsNil bool
+	// Optional, how the requests are retried, see DefaultRetryPolicy
+	RetryPolicy *RetryPolicy
+	// Optional, stop recording the requests of the client into MetricCollector
+	DisableMetricInterceptor bool
+	// Optional, stop creating the otel spans of the requests
+	DisableTraceInterceptor bool
+	// Optional, the provider of the spans, default otel.GetTracerProvider()
+	TracerProvider trace.TracerProvider
+	// Optional, record the sha256 of the key in the spans instead of the key
+	HashTraceKey bool
+	// Optional, add the DNS lookup, connection, TLS handshake and time to first byte of the requests as events to their spans
+	EnableClientTrace bool
+	// Only for file, the url NewFileHandler is served at, e.g. http://127.0.0.1:8080/awos, required by SignURL
+	FileURL string
 }
 
 const (
@@ -76,119 +134,278 @@ const (
 
 // New awos Client instance
 func New(options *Options) (Client, error) {
-	Register(DefaultGzipCompressor)
-	cfg := DefaultConfig()
-	cfg.StorageType = strings.ToLower(options.StorageType)
-	if cfg.StorageType == StorageTypeOSS {
-		client, err := oss.New(options.Endpoint, options.AccessKeyID, options.AccessKeySecret)
+	cfg := newConfig(options)
+	switch cfg.StorageType {
+	case StorageTypeOSS:
+		client, err := newOSSClient(cfg, options)
 		if err != nil {
 			return nil, err
 		}
+		return newOSS(cfg, options, client)
+	case StorageTypeS3:
+		return newS3(cfg, options, newS3Session(cfg, options))
+	case StorageTypeFile:
+		return newFile(cfg, options)
+	default:
+		return nil, fmt.Errorf(`unknown StorageType:"%s", only supports oss, s3 or file`, options.StorageType)
+	}
+}
 
-		var ossClient = &OSS{cfg: cfg}
-		if options.Shards != nil && len(options.Shards) > 0 {
-			buckets := make(map[string]*oss.Bucket)
-			for _, v := range options.Shards {
-				bucket, err := client.Bucket(options.Bucket + "-" + v)
-				if err != nil {
-					return nil, err
-				}
-				for i := 0; i < len(v); i++ {
-					buckets[strings.ToLower(v[i:i+1])] = bucket
-				}
-			}
-			ossClient.Shards = buckets
-		} else {
-			bucket, err := client.Bucket(options.Bucket)
+// newConfig returns the config of the options
+func newConfig(options *Options) *Config {
+	cfg := DefaultConfig()
+	cfg.StorageType = strings.ToLower(options.StorageType)
+	cfg.AccessKeyID = options.AccessKeyID
+	cfg.AccessKeySecret = options.AccessKeySecret
+	cfg.Endpoint = options.Endpoint
+	cfg.Bucket = options.Bucket
+	cfg.Shards = options.Shards
+	cfg.Region = options.Region
+	cfg.S3ForcePathStyle = options.S3ForcePathStyle
+	cfg.SSL = options.SSL
+	cfg.S3HttpTimeoutSecs = options.S3HttpTimeoutSecs
+	cfg.S3HttpTransportMaxConnsPerHost = options.S3HttpTransportMaxConnsPerHost
+	cfg.S3HttpTransportMaxIdleConns = options.S3HttpTransportMaxIdleConns
+	cfg.S3HttpTransportIdleConnTimeoutSecs = int64(options.S3HttpTransportIdleConnTimeout / time.Second)
+	cfg.NotFoundAsNil = options.NotFoundAsNil
+	cfg.EnableDecompressor = options.EnableDecompressor
+	cfg.EnableMetricInterceptor = !options.DisableMetricInterceptor
+	cfg.EnableTraceInterceptor = !options.DisableTraceInterceptor
+	cfg.HashTraceKey = options.HashTraceKey
+	cfg.tracerProvider = options.TracerProvider
+	cfg.EnableClientTrace = options.EnableClientTrace
+	cfg.FileURL = options.FileURL
+	return cfg
+}
+
+// newOSSClient returns the oss client of the endpoint and credentials, it may be shared by the buckets
+func newOSSClient(cfg *Config, options *Options) (*oss.Client, error) {
+	if options.CredentialsProvider != nil {
+		provider := &ossCredentials{cache: newCredentialsCache(options.CredentialsProvider)}
+		return oss.New(options.Endpoint, "", "", oss.SetCredentialsProvider(provider), ossHTTPClient(cfg))
+	}
+	return oss.New(options.Endpoint, options.AccessKeyID, options.AccessKeySecret, ossHTTPClient(cfg))
+}
+
+func newOSS(cfg *Config, options *Options, client *oss.Client) (Client, error) {
+	var ossClient = &OSS{cfg: cfg, retryPolicy: newRetryPolicy(options.RetryPolicy), interceptors: getInterceptors(cfg)}
+	if options.Shards != nil && len(options.Shards) > 0 {
+		buckets := make(map[string]*oss.Bucket)
+		for _, v := range options.Shards {
+			bucket, err := client.Bucket(options.Bucket + "-" + v)
 			if err != nil {
 				return nil, err
 			}
-			ossClient.Bucket = bucket
-		}
-		if options.EnableCompressor {
-			// 目前仅支持 gzip
-			ossClient.cfg.EnableCompressor = options.EnableCompressor
-			ossClient.cfg.CompressType = options.CompressType
-			ossClient.cfg.CompressLimit = options.CompressLimit
-			if comp, ok := compressors[options.CompressType]; ok {
-				ossClient.compressor = comp
-			} else {
-				fmt.Printf("unknown type is: %s", options.CompressType)
-			}
+			buckets[v] = bucket
 		}
-		return ossClient, nil
-	} else if cfg.StorageType == StorageTypeS3 {
-		var config *aws.Config
-		// use minio
-		if options.S3ForcePathStyle {
-			config = &aws.Config{
-				Region:           aws.String(options.Region),
-				DisableSSL:       aws.Bool(!options.SSL),
-				Credentials:      credentials.NewStaticCredentials(options.AccessKeyID, options.AccessKeySecret, ""),
-				Endpoint:         aws.String(options.Endpoint),
-				S3ForcePathStyle: aws.Bool(true),
-			}
-		} else {
-			config = &aws.Config{
-				Region:      aws.String(options.Region),
-				DisableSSL:  aws.Bool(!options.SSL),
-				Credentials: credentials.NewStaticCredentials(options.AccessKeyID, options.AccessKeySecret, ""),
-			}
-			if options.Endpoint != "" {
-				config.Endpoint = aws.String(options.Endpoint)
-			}
+		ossClient.Shards = buckets
+		ossClient.ShardRouter = getShardRouter(options)
+	} else {
+		bucket, err := client.Bucket(options.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		ossClient.Bucket = bucket
+	}
+	if options.EnableCompressor {
+		comp, err := getCompressor(options.CompressType)
+		if err != nil {
+			return nil, err
 		}
+		ossClient.cfg.EnableCompressor = options.EnableCompressor
+		ossClient.cfg.CompressType = options.CompressType
+		ossClient.cfg.CompressLimit = options.CompressLimit
+		ossClient.compressor = comp
+	}
+	return ossClient, nil
+}
 
-		httpTimeout := DefaultHttpTimeout
-		if options.S3HttpTimeoutSecs > 0 {
-			httpTimeout = options.S3HttpTimeoutSecs
+// newS3Session returns the session of the endpoint and credentials with the http client, it may be shared by the buckets
+func newS3Session(cfg *Config, options *Options) *session.Session {
+	var config *aws.Config
+	// use minio
+	if options.S3ForcePathStyle {
+		config = &aws.Config{
+			Region:           aws.String(options.Region),
+			DisableSSL:       aws.Bool(!options.SSL),
+			Credentials:      credentials.NewStaticCredentials(options.AccessKeyID, options.AccessKeySecret, ""),
+			Endpoint:         aws.String(options.Endpoint),
+			S3ForcePathStyle: aws.Bool(true),
 		}
-		httpClient := &http.Client{
-			Timeout: time.Second * time.Duration(httpTimeout),
+	} else {
+		config = &aws.Config{
+			Region:      aws.String(options.Region),
+			DisableSSL:  aws.Bool(!options.SSL),
+			Credentials: credentials.NewStaticCredentials(options.AccessKeyID, options.AccessKeySecret, ""),
 		}
-		if options.S3HttpTransportMaxConnsPerHost > 0 {
-			transport := &http.Transport{
-				MaxIdleConns:      options.S3HttpTransportMaxConnsPerHost,
-				IdleConnTimeout:   30 * time.Second,
-				MaxConnsPerHost:   options.S3HttpTransportMaxConnsPerHost,
-				ForceAttemptHTTP2: true,
-			}
-			if options.S3HttpTransportMaxIdleConns > 0 {
-				transport.MaxIdleConns = options.S3HttpTransportMaxIdleConns
-			}
-			if options.S3HttpTransportIdleConnTimeout != 0 {
-				transport.IdleConnTimeout = options.S3HttpTransportIdleConnTimeout
-			}
-			httpClient.Transport = transport
+		if options.Endpoint != "" {
+			config.Endpoint = aws.String(options.Endpoint)
 		}
-		config.HTTPClient = httpClient
-		service := s3.New(session.Must(session.NewSession(config)))
+	}
+
+	if options.CredentialsProvider != nil {
+		config.Credentials = credentials.NewCredentials(&awsCredentials{cache: newCredentialsCache(options.CredentialsProvider)})
+	}
 
-		var s3Client = &S3{Client: service, cfg: cfg}
-		if options.Shards != nil && len(options.Shards) > 0 {
-			buckets := make(map[string]string)
-			for _, v := range options.Shards {
-				for i := 0; i < len(v); i++ {
-					buckets[strings.ToLower(v[i:i+1])] = options.Bucket + "-" + v
+	httpTimeout := DefaultHttpTimeout
+	if options.S3HttpTimeoutSecs > 0 {
+		httpTimeout = options.S3HttpTimeoutSecs
+	}
+	httpClient := &http.Client{
+		Timeout: time.Second * time.Duration(httpTimeout),
+	}
+	if options.S3HttpTransportMaxConnsPerHost > 0 {
+		transport := &http.Transport{
+			MaxIdleConns:      options.S3HttpTransportMaxConnsPerHost,
+			IdleConnTimeout:   30 * time.Second,
+			MaxConnsPerHost:   options.S3HttpTransportMaxConnsPerHost,
+			ForceAttemptHTTP2: true,
+		}
+		if options.S3HttpTransportMaxIdleConns > 0 {
+			transport.MaxIdleConns = options.S3HttpTransportMaxIdleConns
+		}
+		if options.S3HttpTransportIdleConnTimeout != 0 {
+			transport.IdleConnTimeout = options.S3HttpTransportIdleConnTimeout
+		}
+		httpClient.Transport = transport
+	}
+	config.HTTPClient = httpClient
+	// requests are retried by RetryPolicy instead of the sdk
+	config.MaxRetries = aws.Int(0)
+	sess := session.Must(session.NewSession(config))
+	// wrapped after the session is created, which may set up the tls of the *http.Transport, e.g. AWS_CA_BUNDLE
+	httpClient.Transport = wrapTransport(httpClient.Transport, cfg)
+	return sess
+}
+
+func newS3(cfg *Config, options *Options, sess *session.Session) (Client, error) {
+	service := s3.New(sess)
+	if cfg.EnableTraceInterceptor {
+		service.Handlers.Complete.PushBackNamed(request.NamedHandler{Name: s3TraceHandlerName, Fn: traceS3Request})
+	}
+
+	var s3Client = &S3{Client: service, cfg: cfg, retryPolicy: newRetryPolicy(options.RetryPolicy), interceptors: getInterceptors(cfg)}
+	if options.Shards != nil && len(options.Shards) > 0 {
+		buckets := make(map[string]string)
+		for _, v := range options.Shards {
+			buckets[v] = options.Bucket + "-" + v
+		}
+		s3Client.ShardsBucket = buckets
+		s3Client.ShardRouter = getShardRouter(options)
+	} else {
+		s3Client.BucketName = options.Bucket
+	}
+	if options.EnableCompressor {
+		comp, err := getCompressor(options.CompressType)
+		if err != nil {
+			return nil, err
+		}
+		s3Client.cfg.EnableCompressor = options.EnableCompressor
+		s3Client.cfg.CompressType = options.CompressType
+		s3Client.cfg.CompressLimit = options.CompressLimit
+		s3Client.compressor = comp
+	}
+	return s3Client, nil
+}
+
+func getShardRouter(options *Options) ShardRouter {
+	if options.ShardRouter != nil {
+		return options.ShardRouter
+	}
+	return NewLastCharRouter(options.Shards)
+}
+
+// wrapTransport wraps the transport of the sdk, nil means http.DefaultTransport
+func wrapTransport(base http.RoundTripper, cfg *Config) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg.EnableClientTrace {
+		base = &clientTraceTransport{base: base}
+	}
+	if cfg.EnableMetricInterceptor {
+		base = &metricTransport{base: base}
+	}
+	// s3 records the responses into the spans by its handlers
+	if cfg.EnableTraceInterceptor && cfg.StorageType == StorageTypeOSS {
+		base = &traceTransport{base: base}
+	}
+	return &retryAfterTransport{base: base}
+}
+
+// ossHTTPClient replaces the http client of oss by the one the sdk creates, with the wrapped transport:
+// the dial and read/write timeouts, the proxy, the tls and the redirect settings of the config are kept.
+// It must be the last option so that it sees the settings of the other options.
+func ossHTTPClient(cfg *Config) oss.ClientOption {
+	return func(client *oss.Client) {
+		conf := client.Config
+		timeout := conf.HTTPTimeout
+		dialer := &net.Dialer{Timeout: timeout.ConnectTimeout, KeepAlive: 30 * time.Second, LocalAddr: conf.LocalAddr}
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+				conn, err := dialer.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
 				}
+				return newTimeoutConn(conn, timeout.ReadWriteTimeout, timeout.LongTimeout), nil
+			},
+			MaxIdleConns:          conf.HTTPMaxConns.MaxIdleConns,
+			MaxIdleConnsPerHost:   conf.HTTPMaxConns.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       conf.HTTPMaxConns.MaxConnsPerHost,
+			IdleConnTimeout:       timeout.IdleConnTimeout,
+			ResponseHeaderTimeout: timeout.HeaderTimeout,
+		}
+		if conf.InsecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		if conf.IsUseProxy {
+			proxyURL, err := url.Parse(conf.ProxyHost)
+			if err != nil {
+				// the sdk creates its own client and returns the error
+				return
 			}
-			s3Client.ShardsBucket = buckets
-		} else {
-			s3Client.BucketName = options.Bucket
-		}
-		if options.EnableCompressor {
-			// 目前仅支持 gzip
-			s3Client.cfg.EnableCompressor = options.EnableCompressor
-			s3Client.cfg.CompressType = options.CompressType
-			s3Client.cfg.CompressLimit = options.CompressLimit
-			if comp, ok := compressors[options.CompressType]; ok {
-				s3Client.compressor = comp
-			} else {
-				fmt.Printf("unknown type is: %s", options.CompressType)
+			if conf.IsAuthProxy {
+				if conf.ProxyPassword != "" {
+					proxyURL.User = url.UserPassword(conf.ProxyUser, conf.ProxyPassword)
+				} else {
+					proxyURL.User = url.User(conf.ProxyUser)
+				}
 			}
+			transport.Proxy = http.ProxyURL(proxyURL)
 		}
-		return s3Client, nil
-	} else {
-		return nil, fmt.Errorf(`unknown StorageType:"%s", only supports oss or s3`, options.StorageType)
+		httpClient := &http.Client{Transport: wrapTransport(transport, cfg)}
+		if !conf.RedirectEnabled {
+			httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
+		client.HTTPClient = httpClient
 	}
 }
+
+// timeoutConn is the connection of oss with a deadline for each read and write,
+// and the long timeout between them, like the one of the sdk
+type timeoutConn struct {
+	net.Conn
+	timeout     time.Duration
+	longTimeout time.Duration
+}
+
+func newTimeoutConn(conn net.Conn, timeout time.Duration, longTimeout time.Duration) *timeoutConn {
+	_ = conn.SetReadDeadline(time.Now().Add(longTimeout))
+	return &timeoutConn{Conn: conn, timeout: timeout, longTimeout: longTimeout}
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	_ = c.SetReadDeadline(time.Now().Add(c.timeout))
+	n, err := c.Conn.Read(b)
+	_ = c.SetReadDeadline(time.Now().Add(c.longTimeout))
+	return n, err
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	n, err := c.Conn.Write(b)
+	_ = c.SetReadDeadline(time.Now().Add(c.longTimeout))
+	return n, err
+}
@@ -0,0 +1,161 @@
+package awos
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// events of the client trace, added to the span of the request
+const (
+	eventDNSDone          = "dns.done"
+	eventConnectDone      = "connect.done"
+	eventGotConn          = "http.got_conn"
+	eventTLSHandshakeDone = "tls.handshake.done"
+	eventWroteRequest     = "http.wrote_request"
+	eventFirstByte        = "http.first_response_byte"
+)
+
+const (
+	attrDuration   = attribute.Key("duration_ms")
+	attrSinceStart = attribute.Key("since_start_ms")
+	attrAddr       = attribute.Key("net.peer.addr")
+	attrReused     = attribute.Key("reused")
+	attrWasIdle    = attribute.Key("was_idle")
+	attrIdleTime   = attribute.Key("idle_time_ms")
+	attrTLSVersion = attribute.Key("tls.version")
+	attrTLSResumed = attribute.Key("tls.resumed")
+	attrEventError = attribute.Key("error")
+)
+
+// clientTraceTransport adds the DNS lookup, connection, TLS handshake and time to first byte of the requests
+// as events to the span of the request, so that a slow request shows whether the time went to connecting or to the server
+type clientTraceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *clientTraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return t.base.RoundTrip(req)
+	}
+	ct := &clientTrace{span: span, start: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), ct.trace()))
+	return t.base.RoundTrip(req)
+}
+
+// clientTrace keeps the start of the phases of a request, the hooks may be called from the goroutine dialing the connection
+type clientTrace struct {
+	span  trace.Span
+	start time.Time
+
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+func (c *clientTrace) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			c.mu.Lock()
+			c.dnsStart = time.Now()
+			c.mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			attrs := []attribute.KeyValue{attrDuration.Float64(c.since(&c.dnsStart))}
+			if info.Err != nil {
+				attrs = append(attrs, attrEventError.String(info.Err.Error()))
+			}
+			c.event(eventDNSDone, attrs...)
+		},
+		ConnectStart: func(network, addr string) {
+			c.mu.Lock()
+			c.connectStart = time.Now()
+			c.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			attrs := []attribute.KeyValue{attrDuration.Float64(c.since(&c.connectStart)), attrAddr.String(addr)}
+			if err != nil {
+				attrs = append(attrs, attrEventError.String(err.Error()))
+			}
+			c.event(eventConnectDone, attrs...)
+		},
+		TLSHandshakeStart: func() {
+			c.mu.Lock()
+			c.tlsStart = time.Now()
+			c.mu.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			attrs := []attribute.KeyValue{
+				attrDuration.Float64(c.since(&c.tlsStart)),
+				attrTLSVersion.String(tlsVersion(state.Version)),
+				attrTLSResumed.Bool(state.DidResume),
+			}
+			if err != nil {
+				attrs = append(attrs, attrEventError.String(err.Error()))
+			}
+			c.event(eventTLSHandshakeDone, attrs...)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			attrs := []attribute.KeyValue{attrReused.Bool(info.Reused), attrWasIdle.Bool(info.WasIdle)}
+			if info.WasIdle {
+				attrs = append(attrs, attrIdleTime.Float64(milliseconds(info.IdleTime)))
+			}
+			if info.Conn != nil {
+				attrs = append(attrs, attrAddr.String(info.Conn.RemoteAddr().String()))
+			}
+			c.event(eventGotConn, attrs...)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			var attrs []attribute.KeyValue
+			if info.Err != nil {
+				attrs = append(attrs, attrEventError.String(info.Err.Error()))
+			}
+			c.event(eventWroteRequest, attrs...)
+		},
+		GotFirstResponseByte: func() {
+			c.event(eventFirstByte)
+		},
+	}
+}
+
+// since returns the milliseconds since the start of a phase
+func (c *clientTrace) since(start *time.Time) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if start.IsZero() {
+		return 0
+	}
+	return milliseconds(time.Since(*start))
+}
+
+// event adds the event with the milliseconds since the start of the request
+func (c *clientTrace) event(name string, attrs ...attribute.KeyValue) {
+	attrs = append(attrs, attrSinceStart.Float64(milliseconds(time.Since(c.start))))
+	c.span.AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func tlsVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	default:
+		return ""
+	}
+}
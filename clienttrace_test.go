@@ -0,0 +1,66 @@
+package awos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClientTrace(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			}))
+			defer server.Close()
+
+			recorder := tracetest.NewSpanRecorder()
+			client, err := New(&Options{
+				StorageType:       storageType,
+				AccessKeyID:       "ak",
+				AccessKeySecret:   "sk",
+				Endpoint:          server.URL,
+				Bucket:            "test",
+				Region:            "cn-north-1",
+				S3ForcePathStyle:  true,
+				TracerProvider:    sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+				EnableClientTrace: true,
+			})
+			assert.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				_, err = client.GetWithContext(context.Background(), "key")
+				assert.NoError(t, err)
+			}
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 2)
+			events := func(span sdktrace.ReadOnlySpan) map[string]map[string]interface{} {
+				res := make(map[string]map[string]interface{})
+				for _, event := range span.Events() {
+					attrs := make(map[string]interface{})
+					for _, kv := range event.Attributes {
+						attrs[string(kv.Key)] = kv.Value.AsInterface()
+					}
+					res[event.Name] = attrs
+				}
+				return res
+			}
+			first := events(spans[0])
+			assert.Contains(t, first, eventConnectDone)
+			assert.Contains(t, first, eventWroteRequest)
+			assert.Contains(t, first, eventFirstByte)
+			assert.Equal(t, false, first[eventGotConn][string(attrReused)])
+
+			second := events(spans[1])
+			assert.NotContains(t, second, eventConnectDone)
+			assert.Equal(t, true, second[eventGotConn][string(attrReused)])
+		})
+	}
+}
@@ -3,20 +3,70 @@ package awos
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
 	"sync"
+
+	"github.com/golang/snappy"
 )
 
 var (
 	compressTypeGzip = "gzip"
 	compressorsMu    sync.RWMutex
-	compressors      = make(map[string]Compressor)
+	compressors      = map[string]Compressor{
+		compressTypeGzip:   DefaultGzipCompressor,
+		compressTypeSnappy: DefaultSnappyCompressor,
+		compressTypeZstd:   DefaultZstdCompressor,
+		compressTypeLZ4:    DefaultLZ4Compressor,
+		compressTypeBrotli: DefaultBrotliCompressor,
+	}
+	decompressors = map[string]Decompressor{
+		compressTypeGzip:   DefaultGzipCompressor,
+		compressTypeSnappy: DefaultSnappyCompressor,
+		compressTypeZstd:   DefaultZstdCompressor,
+		compressTypeLZ4:    DefaultLZ4Compressor,
+		compressTypeBrotli: DefaultBrotliCompressor,
+	}
 )
 
+// Register registers the compressor of its ContentEncoding, it is registered as the decompressor too if it is a Decompressor
 func Register(comp Compressor) {
 	compressorsMu.Lock()
 	defer compressorsMu.Unlock()
 	compressors[comp.ContentEncoding()] = comp
+	if decomp, ok := comp.(Decompressor); ok {
+		decompressors[decomp.ContentEncoding()] = decomp
+	}
+}
+
+// RegisterDecompressor registers the decompressor of its ContentEncoding, used by the Get methods with EnableDecompressor
+func RegisterDecompressor(decomp Decompressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	decompressors[decomp.ContentEncoding()] = decomp
+}
+
+// getDecompressor returns the registered decompressor of the Content-Encoding
+func getDecompressor(encoding string) (Decompressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	decomp, ok := decompressors[strings.ToLower(strings.TrimSpace(encoding))]
+	return decomp, ok
+}
+
+// getCompressor returns the registered compressor of the CompressType
+func getCompressor(compressType string) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	comp, ok := compressors[compressType]
+	if !ok {
+		return nil, fmt.Errorf("awos: unknown CompressType %q", compressType)
+	}
+	return comp, nil
 }
 
 type Compressor interface {
@@ -24,6 +74,19 @@ type Compressor interface {
 	ContentEncoding() string
 }
 
+// Decompressor decodes the objects stored with its ContentEncoding
+type Decompressor interface {
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	ContentEncoding() string
+}
+
+// StreamCompressor is a Compressor which also compresses as a stream,
+// Put pipes the body through it instead of reading the whole body in memory first
+type StreamCompressor interface {
+	Compressor
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
 type GzipCompressor struct{}
 
 func (g *GzipCompressor) Compress(reader io.ReadSeeker) (gzipReader io.ReadSeeker, len int64, err error) {
@@ -40,6 +103,14 @@ func (g *GzipCompressor) Compress(reader io.ReadSeeker) (gzipReader io.ReadSeeke
 	return bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), nil
 }
 
+func (g *GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (g *GzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
 func (g *GzipCompressor) ContentEncoding() string {
 	return compressTypeGzip
 }
@@ -53,3 +124,228 @@ func GetReaderLength(reader io.ReadSeeker) (io.ReadSeeker, int, error) {
 	}
 	return bytes.NewReader(all), len(all), nil
 }
+
+// decodedBody is the body decoded by a decompressor, Close closes both
+type decodedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.body.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// decodeBody returns the body decoded by the decompressor of the Content-Encoding,
+// it is returned as is if the client does not enable the decompressor, the options ask for the raw content
+// or no decompressor is registered for the encoding, e.g. identity
+func (c *Config) decodeBody(body io.ReadCloser, encoding string, getOpts *getOptions) (io.ReadCloser, error) {
+	if c == nil || !c.EnableDecompressor || getOpts.raw {
+		return body, nil
+	}
+	return decompressBody(body, "", encoding)
+}
+
+// acceptEncoding returns the Accept-Encoding of a get request, the content is asked as stored if it is decoded
+// by decodeBody or the raw content is asked, otherwise the http transport decompresses gzip transparently
+func (c *Config) acceptEncoding(getOpts *getOptions) string {
+	if getOpts.raw || (c != nil && c.EnableDecompressor) {
+		return "identity"
+	}
+	return ""
+}
+
+// compressSpoolSize is the size of the compressed body kept in memory by Put, the rest goes to a temporary file
+const compressSpoolSize = 8 << 20
+
+// compressedBody is the body of Put with EnableCompressor, it is compressed if the content has at least CompressLimit bytes
+type compressedBody struct {
+	io.ReadSeeker
+	// size is the length of the body, rawSize the length of the content
+	size, rawSize int64
+	compressed    bool
+	spool         *spool
+}
+
+// Close removes the temporary file of the body
+func (b *compressedBody) Close() error {
+	if b.spool == nil {
+		return nil
+	}
+	return b.spool.Close()
+}
+
+// compressBody reads a sample of limit bytes from the reader to decide on the compression, the smaller contents
+// are returned as is. A StreamCompressor, as every registered compressor is, compresses the content into a spool,
+// only the other compressors get the whole content in memory.
+func compressBody(comp Compressor, reader io.Reader, limit int) (*compressedBody, error) {
+	var sample bytes.Buffer
+	if _, err := io.CopyN(&sample, reader, int64(limit)); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if sample.Len() < limit {
+		return &compressedBody{ReadSeeker: bytes.NewReader(sample.Bytes()), size: int64(sample.Len()), rawSize: int64(sample.Len())}, nil
+	}
+	content := io.MultiReader(&sample, reader)
+
+	streamComp, ok := comp.(StreamCompressor)
+	if !ok {
+		all, err := ioutil.ReadAll(content)
+		if err != nil {
+			return nil, err
+		}
+		body, clen, err := comp.Compress(bytes.NewReader(all))
+		if err != nil {
+			return nil, err
+		}
+		return &compressedBody{ReadSeeker: body, size: clen, rawSize: int64(len(all)), compressed: true}, nil
+	}
+
+	out := &spool{limit: compressSpoolSize}
+	body, err := compressTo(streamComp, out, content)
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	body.spool = out
+	return body, nil
+}
+
+func compressTo(comp StreamCompressor, out *spool, content io.Reader) (*compressedBody, error) {
+	w, err := comp.NewWriter(out)
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(w, content)
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	reader, err := out.reader()
+	if err != nil {
+		return nil, err
+	}
+	return &compressedBody{ReadSeeker: reader, size: out.size, rawSize: n, compressed: true}, nil
+}
+
+// spool keeps the written data in memory up to limit bytes, then in a temporary file
+type spool struct {
+	limit int64
+	size  int64
+	buf   bytes.Buffer
+	file  *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.size+int64(len(p)) > s.limit {
+		file, err := ioutil.TempFile("", "awos-compress-")
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+		if _, err := s.buf.WriteTo(file); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// reader returns the reader of the written data
+func (s *spool) reader() (io.ReadSeeker, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if rerr := os.Remove(s.file.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// compressForPut compresses the content of CompressAndPut with the registered compressor of compressType,
+// it returns the compressed body, its size and the size of the content
+func compressForPut(compressType string, reader io.Reader) (io.ReadSeeker, int64, int64, error) {
+	comp, err := getCompressor(compressType)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	body, size, err := comp.Compress(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return body, size, int64(len(data)), nil
+}
+
+// decompressBody decodes the body of GetAndDecompress by the Content-Encoding of EnableCompressor, the outer layer,
+// then by the Compressor meta of CompressAndPut, e.g. the snappy content of CompressAndPut in the gzip of EnableCompressor.
+// The body is returned as is without either.
+func decompressBody(body io.ReadCloser, compressor string, encoding string) (io.ReadCloser, error) {
+	body, err := decodeLayer(body, encoding, false)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLayer(body, compressor, true)
+}
+
+// decodeLayer decodes a single layer of the body by the registered decompressor of name,
+// an unknown name is an error if it is required, otherwise the body is returned as is, e.g. identity
+func decodeLayer(body io.ReadCloser, name string, required bool) (io.ReadCloser, error) {
+	if name == "" {
+		return body, nil
+	}
+	decomp, ok := getDecompressor(name)
+	if !ok {
+		if !required {
+			return body, nil
+		}
+		body.Close()
+		return nil, fmt.Errorf("awos: GetAndDecompress: unknown compressor %q", name)
+	}
+	r, err := decomp.NewReader(body)
+	if err == io.EOF {
+		// an empty object
+		return body, nil
+	}
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("awos: decode %s: %w", name, err)
+	}
+	return &decodedBody{ReadCloser: r, body: body}, nil
+}
+
+// decodeSnappy decodes either a snappy block, e.g. the content of CompressAndPut, or a snappy stream
+func decodeSnappy(raw []byte) ([]byte, error) {
+	decoded, err := snappy.Decode(nil, raw)
+	if errors.Is(err, snappy.ErrCorrupt) {
+		return ioutil.ReadAll(snappy.NewReader(bytes.NewReader(raw)))
+	}
+	return decoded, err
+}
@@ -1,9 +1,15 @@
 package awos
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // TestCompress_gzip
@@ -12,6 +18,9 @@ import (
 // 测试读取文件后压缩 输出到指定path 对比是否正常
 func TestCompress_gzip(t *testing.T) {
 	path := os.Getenv("src_path")
+	if path == "" {
+		t.Skip("src_path is not set")
+	}
 	source, err := os.Open(path)
 	if err != nil {
 		panic(err)
@@ -36,3 +45,171 @@ func TestCompress_gzip(t *testing.T) {
 		panic(err)
 	}
 }
+
+// bufferedCompressor is a Compressor which is not a StreamCompressor
+type bufferedCompressor struct{}
+
+func (c *bufferedCompressor) Compress(reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	return DefaultGzipCompressor.Compress(reader)
+}
+
+func (c *bufferedCompressor) ContentEncoding() string {
+	return "buffered"
+}
+
+func TestCompressBody(t *testing.T) {
+	content := strings.Repeat("content", 100)
+
+	body, err := compressBody(DefaultGzipCompressor, strings.NewReader(content), len(content)+1)
+	assert.NoError(t, err)
+	assert.False(t, body.compressed)
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, content, string(data))
+	assert.NoError(t, body.Close())
+
+	for _, comp := range []Compressor{DefaultGzipCompressor, &bufferedCompressor{}} {
+		body, err = compressBody(comp, strings.NewReader(content), len(content))
+		assert.NoError(t, err)
+		assert.True(t, body.compressed)
+		assert.Equal(t, int64(len(content)), body.rawSize)
+		assert.Less(t, body.size, body.rawSize)
+		r, err := gzip.NewReader(body)
+		assert.NoError(t, err)
+		data, _ = ioutil.ReadAll(r)
+		assert.Equal(t, content, string(data))
+		assert.NoError(t, body.Close())
+	}
+}
+
+func TestCompressBody_Snappy(t *testing.T) {
+	content := strings.Repeat("content", 100)
+	body, err := compressBody(DefaultSnappyCompressor, strings.NewReader(content), 10)
+	assert.NoError(t, err)
+	// the stream format goes through the spool, it isn't read in memory first
+	assert.True(t, body.compressed)
+	assert.NotNil(t, body.spool)
+	assert.Less(t, body.size, body.rawSize)
+	r, err := decompressBody(ioutil.NopCloser(body), "", compressTypeSnappy)
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.NoError(t, r.Close())
+}
+
+func TestCompressors(t *testing.T) {
+	content := strings.Repeat("content", 100)
+	for _, compressType := range []string{"gzip", "snappy", "zstd", "lz4", "br"} {
+		comp, err := getCompressor(compressType)
+		assert.NoError(t, err, compressType)
+		decomp, ok := getDecompressor(compressType)
+		assert.True(t, ok, compressType)
+
+		body, size, err := comp.Compress(strings.NewReader(content))
+		assert.NoError(t, err, compressType)
+		assert.Less(t, size, int64(len(content)), compressType)
+		r, err := decomp.NewReader(body)
+		assert.NoError(t, err, compressType)
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err, compressType)
+		assert.NoError(t, r.Close())
+		assert.Equal(t, content, string(data), compressType)
+
+		body, size, rawSize, err := compressForPut(compressType, strings.NewReader(content))
+		assert.NoError(t, err, compressType)
+		assert.Equal(t, int64(len(content)), rawSize)
+		raw, _ := ioutil.ReadAll(body)
+		assert.Equal(t, size, int64(len(raw)))
+		// by the Compressor meta of CompressAndPut or the Content-Encoding of EnableCompressor
+		for _, marker := range [][2]string{{compressType, ""}, {"", compressType}} {
+			r, err = decompressBody(ioutil.NopCloser(bytes.NewReader(raw)), marker[0], marker[1])
+			assert.NoError(t, err, compressType)
+			data, err = ioutil.ReadAll(r)
+			assert.NoError(t, err, compressType)
+			assert.NoError(t, r.Close())
+			assert.Equal(t, content, string(data), compressType)
+		}
+	}
+
+	_, _, _, err := compressForPut("lzma", strings.NewReader(content))
+	assert.Error(t, err)
+	_, err = decompressBody(ioutil.NopCloser(strings.NewReader(content)), "lzma", "")
+	assert.Error(t, err)
+	r, err := decompressBody(ioutil.NopCloser(strings.NewReader(content)), "", "identity")
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(r)
+	assert.Equal(t, content, string(data))
+}
+
+func TestDecompressBody_Layers(t *testing.T) {
+	content := strings.Repeat("content", 100)
+	// the snappy of CompressAndPut in the gzip of EnableCompressor
+	snappyBody, _, _, err := compressForPut("snappy", strings.NewReader(content))
+	assert.NoError(t, err)
+	gzipBody, err := compressBody(DefaultGzipCompressor, snappyBody, 1)
+	assert.NoError(t, err)
+	assert.True(t, gzipBody.compressed)
+	raw, _ := ioutil.ReadAll(gzipBody)
+	assert.NoError(t, gzipBody.Close())
+
+	r, err := decompressBody(ioutil.NopCloser(bytes.NewReader(raw)), "snappy", "gzip")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, content, string(data))
+
+	// the snappy content is above the CompressLimit
+	client := newFileClient(t, &Options{EnableCompressor: true, CompressType: "gzip", CompressLimit: 10})
+	assert.NoError(t, client.CompressAndPut("key", strings.NewReader(content), nil))
+	meta, err := client.Head("key", []string{"Content-Encoding", MetaCompressor})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Content-Encoding": "gzip", MetaCompressor: "snappy"}, meta)
+	res, err := client.GetAndDecompress("key")
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+}
+
+func TestZstdCompressor_Dict(t *testing.T) {
+	// a dictionary trained on sheet histories
+	dict, err := ioutil.ReadFile("testdata/sheet.zstd.dict")
+	assert.NoError(t, err)
+	content := `{"sheet":"s1000","rows":[{"cells":[{"v":7000,"style":"bold"},{"v":13000,"style":"italic"}]}],"author":"user0"}`
+	comp := &ZstdCompressor{Dict: dict}
+	body, _, err := comp.Compress(strings.NewReader(content))
+	assert.NoError(t, err)
+	raw, _ := ioutil.ReadAll(body)
+
+	_, err = decodeAll(DefaultZstdCompressor, raw)
+	assert.Error(t, err)
+	data, err := decodeAll(&ZstdCompressor{Dicts: [][]byte{dict}}, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func decodeAll(decomp Decompressor, raw []byte) ([]byte, error) {
+	r, err := decomp.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestSpool(t *testing.T) {
+	s := &spool{limit: 10}
+	_, _ = s.Write([]byte("01234"))
+	assert.Nil(t, s.file)
+	_, _ = s.Write([]byte("56789abc"))
+	assert.NotNil(t, s.file)
+	reader, err := s.reader()
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "0123456789abc", string(data))
+	assert.Equal(t, int64(13), s.size)
+
+	name := s.file.Name()
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
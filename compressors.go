@@ -0,0 +1,160 @@
+package awos
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	compressTypeSnappy = "snappy"
+	compressTypeZstd   = "zstd"
+	compressTypeLZ4    = "lz4"
+	compressTypeBrotli = "br"
+)
+
+// ZstdCompressor compresses with zstandard, with the dictionary Dict if any.
+// The decoder knows Dict and Dicts, the dictionaries of the objects written before, the frames refer to them by id.
+// Register a ZstdCompressor with the dictionaries to replace DefaultZstdCompressor.
+type ZstdCompressor struct {
+	Dict  []byte
+	Dicts [][]byte
+}
+
+func (z *ZstdCompressor) Compress(reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	return compressAll(z, reader)
+}
+
+func (z *ZstdCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if len(z.Dict) > 0 {
+		return zstd.NewWriter(w, zstd.WithEncoderDict(z.Dict))
+	}
+	return zstd.NewWriter(w)
+}
+
+func (z *ZstdCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	dicts := z.Dicts
+	if len(z.Dict) > 0 {
+		dicts = append([][]byte{z.Dict}, dicts...)
+	}
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderDicts(dicts...))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+func (z *ZstdCompressor) ContentEncoding() string {
+	return compressTypeZstd
+}
+
+var DefaultZstdCompressor = &ZstdCompressor{}
+
+// LZ4Compressor compresses with the lz4 frame format, faster than gzip for a lower ratio
+type LZ4Compressor struct{}
+
+func (l *LZ4Compressor) Compress(reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	return compressAll(l, reader)
+}
+
+func (l *LZ4Compressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return lz4.NewWriter(w), nil
+}
+
+func (l *LZ4Compressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(lz4.NewReader(r)), nil
+}
+
+func (l *LZ4Compressor) ContentEncoding() string {
+	return compressTypeLZ4
+}
+
+var DefaultLZ4Compressor = &LZ4Compressor{}
+
+// BrotliCompressor compresses with brotli at the default quality
+type BrotliCompressor struct{}
+
+func (b *BrotliCompressor) Compress(reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	return compressAll(b, reader)
+}
+
+func (b *BrotliCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+}
+
+func (b *BrotliCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+func (b *BrotliCompressor) ContentEncoding() string {
+	return compressTypeBrotli
+}
+
+var DefaultBrotliCompressor = &BrotliCompressor{}
+
+// snappyStreamMagic starts the snappy stream format
+const snappyStreamMagic = "\xff\x06\x00\x00sNaPpY"
+
+// SnappyCompressor compresses the content of CompressAndPut in the snappy block format, which older readers decode,
+// and the body of Put with EnableCompressor in the snappy stream format, so that it isn't read in memory first.
+// The decoder reads both.
+type SnappyCompressor struct{}
+
+func (s *SnappyCompressor) Compress(reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	encoded := snappy.Encode(nil, data)
+	return bytes.NewReader(encoded), int64(len(encoded)), nil
+}
+
+func (s *SnappyCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (s *SnappyCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	// the stream is decoded as it is read, the block at once
+	if magic, err := buffered.Peek(len(snappyStreamMagic)); err == nil && string(magic) == snappyStreamMagic {
+		return ioutil.NopCloser(snappy.NewReader(buffered)), nil
+	}
+	raw, err := ioutil.ReadAll(buffered)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeSnappy(raw)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(decoded)), nil
+}
+
+func (s *SnappyCompressor) ContentEncoding() string {
+	return compressTypeSnappy
+}
+
+var DefaultSnappyCompressor = &SnappyCompressor{}
+
+// compressAll compresses the whole content in memory with the writer of the compressor
+func compressAll(comp StreamCompressor, reader io.ReadSeeker) (io.ReadSeeker, int64, error) {
+	var buffer bytes.Buffer
+	w, err := comp.NewWriter(&buffer)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err = io.Copy(w, reader); err != nil {
+		w.Close()
+		return nil, 0, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), nil
+}
@@ -1,56 +1,231 @@
 package awos
 
-type config struct {
-	Debug bool
-	bucketConfig
-	Buckets   map[string]bucketConfig
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config of the clients, the embedded BucketConfig is shared by the Buckets, see NewManager
+type Config struct {
+	Debug        bool `json:"debug" yaml:"debug" toml:"debug"`
+	BucketConfig `yaml:",inline"`
+	// Buckets logical name => bucket, e.g. fileContent, the empty fields are taken from the embedded BucketConfig
+	Buckets map[string]BucketOverride `json:"buckets" yaml:"buckets" toml:"buckets"`
+	// bucketKey the logical name of the bucket in Buckets
 	bucketKey string
+	// tracerProvider of the spans, otel.GetTracerProvider() if nil
+	tracerProvider trace.TracerProvider
 }
 
-type bucketConfig struct {
-	// Required, value is one of oss/s3, case insensetive
-	StorageType string
+// BucketConfig of a client
+type BucketConfig struct {
+	// Required, value is one of oss/s3/file, case insensetive
+	StorageType string `json:"storageType" yaml:"storageType" toml:"storageType"`
 	// Required
-	AccessKeyID string
+	AccessKeyID string `json:"accessKeyID" yaml:"accessKeyID" toml:"accessKeyID"`
 	// Required
-	AccessKeySecret string
+	AccessKeySecret string `json:"accessKeySecret" yaml:"accessKeySecret" toml:"accessKeySecret"`
+	// Required, the root directory for file
+	Endpoint string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
 	// Required
-	Endpoint string
-	// Required
-	Bucket string
+	Bucket string `json:"bucket" yaml:"bucket" toml:"bucket"`
 	// Optional, choose which bucket to use based on the last character of the key,
 	// if bucket is 'content', shards is ['abc', 'edf'],
 	// then the last character of the key with a/b/c will automatically use the content-abc bucket, and vice versa
-	Shards []string
+	Shards []string `json:"shards" yaml:"shards" toml:"shards"`
 	// Only for s3-like
-	Region string
+	Region string `json:"region" yaml:"region" toml:"region"`
 	// Only for s3-like, whether to force path style URLs for S3 objects.
-	S3ForcePathStyle bool
+	S3ForcePathStyle bool `json:"s3ForcePathStyle" yaml:"s3ForcePathStyle" toml:"s3ForcePathStyle"`
 	// Only for s3-like
-	SSL bool
+	SSL bool `json:"ssl" yaml:"ssl" toml:"ssl"`
 	// Only for s3-like, set http client timeout.
 	// oss has default timeout, but s3 default timeout is 0 means no timeout.
-	S3HttpTimeoutSecs int64
-	// EnableTraceInterceptor enable otel trace (only for s3)
-	EnableTraceInterceptor bool
+	S3HttpTimeoutSecs int64 `json:"s3HttpTimeoutSecs" yaml:"s3HttpTimeoutSecs" toml:"s3HttpTimeoutSecs"`
+	// Only for s3-like, the transport settings of the http client, the default transport is used if S3HttpTransportMaxConnsPerHost is 0
+	S3HttpTransportMaxConnsPerHost     int   `json:"s3HttpTransportMaxConnsPerHost" yaml:"s3HttpTransportMaxConnsPerHost" toml:"s3HttpTransportMaxConnsPerHost"`
+	S3HttpTransportMaxIdleConns        int   `json:"s3HttpTransportMaxIdleConns" yaml:"s3HttpTransportMaxIdleConns" toml:"s3HttpTransportMaxIdleConns"`
+	S3HttpTransportIdleConnTimeoutSecs int64 `json:"s3HttpTransportIdleConnTimeoutSecs" yaml:"s3HttpTransportIdleConnTimeoutSecs" toml:"s3HttpTransportIdleConnTimeoutSecs"`
+	// EnableTraceInterceptor enable otel trace
+	EnableTraceInterceptor bool `json:"enableTraceInterceptor" yaml:"enableTraceInterceptor" toml:"enableTraceInterceptor"`
+	// HashTraceKey record the sha256 of the key in the spans instead of the key
+	HashTraceKey bool `json:"hashTraceKey" yaml:"hashTraceKey" toml:"hashTraceKey"`
 	// EnableMetricInterceptor enable prom metrics
-	EnableMetricInterceptor bool
-	// EnableClientTrace
-	EnableClientTrace bool
+	EnableMetricInterceptor bool `json:"enableMetricInterceptor" yaml:"enableMetricInterceptor" toml:"enableMetricInterceptor"`
+	// EnableClientTrace add the httptrace of the requests as events to their spans
+	EnableClientTrace bool `json:"enableClientTrace" yaml:"enableClientTrace" toml:"enableClientTrace"`
 	// EnableCompressor
-	EnableCompressor bool
+	EnableCompressor bool `json:"enableCompressor" yaml:"enableCompressor" toml:"enableCompressor"`
 	// CompressType gzip
-	CompressType string
+	CompressType string `json:"compressType" yaml:"compressType" toml:"compressType"`
 	// CompressLimit 大于该值之后才压缩 单位字节
-	CompressLimit int
+	CompressLimit int `json:"compressLimit" yaml:"compressLimit" toml:"compressLimit"`
+	// EnableDecompressor decodes the objects by their Content-Encoding on Get
+	EnableDecompressor bool `json:"enableDecompressor" yaml:"enableDecompressor" toml:"enableDecompressor"`
+	// NotFoundAsNil return nil instead of ErrNotFound for missing objects
+	NotFoundAsNil bool `json:"notFoundAsNil" yaml:"notFoundAsNil" toml:"notFoundAsNil"`
+	// Only for file, the url NewFileHandler is served at, required by SignURL
+	FileURL string `json:"fileURL" yaml:"fileURL" toml:"fileURL"`
+}
+
+// BucketOverride of a bucket in Config.Buckets, see BucketConfig for the fields.
+// The empty fields are taken from the embedded BucketConfig of Config, the booleans are pointers so that a bucket
+// may disable what the shared config enables, e.g. EnableTraceInterceptor: awos.Bool(false)
+type BucketOverride struct {
+	StorageType                        string   `json:"storageType" yaml:"storageType" toml:"storageType"`
+	AccessKeyID                        string   `json:"accessKeyID" yaml:"accessKeyID" toml:"accessKeyID"`
+	AccessKeySecret                    string   `json:"accessKeySecret" yaml:"accessKeySecret" toml:"accessKeySecret"`
+	Endpoint                           string   `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
+	Bucket                             string   `json:"bucket" yaml:"bucket" toml:"bucket"`
+	Shards                             []string `json:"shards" yaml:"shards" toml:"shards"`
+	Region                             string   `json:"region" yaml:"region" toml:"region"`
+	S3ForcePathStyle                   *bool    `json:"s3ForcePathStyle" yaml:"s3ForcePathStyle" toml:"s3ForcePathStyle"`
+	SSL                                *bool    `json:"ssl" yaml:"ssl" toml:"ssl"`
+	S3HttpTimeoutSecs                  int64    `json:"s3HttpTimeoutSecs" yaml:"s3HttpTimeoutSecs" toml:"s3HttpTimeoutSecs"`
+	S3HttpTransportMaxConnsPerHost     int      `json:"s3HttpTransportMaxConnsPerHost" yaml:"s3HttpTransportMaxConnsPerHost" toml:"s3HttpTransportMaxConnsPerHost"`
+	S3HttpTransportMaxIdleConns        int      `json:"s3HttpTransportMaxIdleConns" yaml:"s3HttpTransportMaxIdleConns" toml:"s3HttpTransportMaxIdleConns"`
+	S3HttpTransportIdleConnTimeoutSecs int64    `json:"s3HttpTransportIdleConnTimeoutSecs" yaml:"s3HttpTransportIdleConnTimeoutSecs" toml:"s3HttpTransportIdleConnTimeoutSecs"`
+	EnableTraceInterceptor             *bool    `json:"enableTraceInterceptor" yaml:"enableTraceInterceptor" toml:"enableTraceInterceptor"`
+	HashTraceKey                       *bool    `json:"hashTraceKey" yaml:"hashTraceKey" toml:"hashTraceKey"`
+	EnableMetricInterceptor            *bool    `json:"enableMetricInterceptor" yaml:"enableMetricInterceptor" toml:"enableMetricInterceptor"`
+	EnableClientTrace                  *bool    `json:"enableClientTrace" yaml:"enableClientTrace" toml:"enableClientTrace"`
+	EnableCompressor                   *bool    `json:"enableCompressor" yaml:"enableCompressor" toml:"enableCompressor"`
+	CompressType                       string   `json:"compressType" yaml:"compressType" toml:"compressType"`
+	CompressLimit                      int      `json:"compressLimit" yaml:"compressLimit" toml:"compressLimit"`
+	EnableDecompressor                 *bool    `json:"enableDecompressor" yaml:"enableDecompressor" toml:"enableDecompressor"`
+	NotFoundAsNil                      *bool    `json:"notFoundAsNil" yaml:"notFoundAsNil" toml:"notFoundAsNil"`
+	FileURL                            string   `json:"fileURL" yaml:"fileURL" toml:"fileURL"`
+}
+
+// Bool returns a pointer to v, for the booleans of BucketOverride
+func Bool(v bool) *bool {
+	return &v
 }
 
 // DefaultConfig 返回默认配置
-func DefaultConfig() *config {
-	return &config{bucketConfig: bucketConfig{
+func DefaultConfig() *Config {
+	return &Config{BucketConfig: BucketConfig{
 		StorageType:             "s3",
 		S3HttpTimeoutSecs:       60,
 		EnableTraceInterceptor:  true,
 		EnableMetricInterceptor: true,
 	}}
 }
+
+// bucket returns the config of the bucket in Buckets, the empty fields are taken from c.
+// Bucket and Shards are never shared, the booleans of the bucket replace the ones of c if they are set.
+func (c *Config) bucket(name string) (*Config, error) {
+	b, ok := c.Buckets[name]
+	if !ok {
+		return nil, fmt.Errorf("awos: unknown bucket %q", name)
+	}
+	if b.Bucket == "" {
+		return nil, fmt.Errorf("awos: bucket %q has no Bucket", name)
+	}
+	res := &Config{Debug: c.Debug, BucketConfig: c.BucketConfig, bucketKey: name, tracerProvider: c.tracerProvider}
+	res.Bucket = b.Bucket
+	res.Shards = b.Shards
+	if b.StorageType != "" {
+		res.StorageType = b.StorageType
+	}
+	res.StorageType = strings.ToLower(res.StorageType)
+	if b.AccessKeyID != "" {
+		res.AccessKeyID = b.AccessKeyID
+		res.AccessKeySecret = b.AccessKeySecret
+	}
+	if b.Endpoint != "" {
+		res.Endpoint = b.Endpoint
+	}
+	if b.Region != "" {
+		res.Region = b.Region
+	}
+	if b.S3HttpTimeoutSecs > 0 {
+		res.S3HttpTimeoutSecs = b.S3HttpTimeoutSecs
+	}
+	if b.S3HttpTransportMaxConnsPerHost > 0 {
+		res.S3HttpTransportMaxConnsPerHost = b.S3HttpTransportMaxConnsPerHost
+	}
+	if b.S3HttpTransportMaxIdleConns > 0 {
+		res.S3HttpTransportMaxIdleConns = b.S3HttpTransportMaxIdleConns
+	}
+	if b.S3HttpTransportIdleConnTimeoutSecs > 0 {
+		res.S3HttpTransportIdleConnTimeoutSecs = b.S3HttpTransportIdleConnTimeoutSecs
+	}
+	if b.CompressType != "" {
+		res.CompressType = b.CompressType
+	}
+	if b.FileURL != "" {
+		res.FileURL = b.FileURL
+	}
+	if b.CompressLimit > 0 {
+		res.CompressLimit = b.CompressLimit
+	}
+	if b.S3ForcePathStyle != nil {
+		res.S3ForcePathStyle = *b.S3ForcePathStyle
+	}
+	if b.SSL != nil {
+		res.SSL = *b.SSL
+	}
+	if b.EnableTraceInterceptor != nil {
+		res.EnableTraceInterceptor = *b.EnableTraceInterceptor
+	}
+	if b.HashTraceKey != nil {
+		res.HashTraceKey = *b.HashTraceKey
+	}
+	if b.EnableMetricInterceptor != nil {
+		res.EnableMetricInterceptor = *b.EnableMetricInterceptor
+	}
+	if b.EnableClientTrace != nil {
+		res.EnableClientTrace = *b.EnableClientTrace
+	}
+	if b.EnableCompressor != nil {
+		res.EnableCompressor = *b.EnableCompressor
+	}
+	if b.EnableDecompressor != nil {
+		res.EnableDecompressor = *b.EnableDecompressor
+	}
+	if b.NotFoundAsNil != nil {
+		res.NotFoundAsNil = *b.NotFoundAsNil
+	}
+	return res, nil
+}
+
+// Options returns the Options of New with the same settings as the embedded BucketConfig of c
+func (c *Config) Options() *Options {
+	return &Options{
+		StorageType:                    c.StorageType,
+		AccessKeyID:                    c.AccessKeyID,
+		AccessKeySecret:                c.AccessKeySecret,
+		Endpoint:                       c.Endpoint,
+		Bucket:                         c.Bucket,
+		Shards:                         c.Shards,
+		Region:                         c.Region,
+		S3ForcePathStyle:               c.S3ForcePathStyle,
+		SSL:                            c.SSL,
+		S3HttpTimeoutSecs:              c.S3HttpTimeoutSecs,
+		S3HttpTransportMaxConnsPerHost: c.S3HttpTransportMaxConnsPerHost,
+		S3HttpTransportMaxIdleConns:    c.S3HttpTransportMaxIdleConns,
+		S3HttpTransportIdleConnTimeout: time.Duration(c.S3HttpTransportIdleConnTimeoutSecs) * time.Second,
+		EnableCompressor:               c.EnableCompressor,
+		CompressType:                   c.CompressType,
+		CompressLimit:                  c.CompressLimit,
+		EnableDecompressor:             c.EnableDecompressor,
+		NotFoundAsNil:                  c.NotFoundAsNil,
+		DisableMetricInterceptor:       !c.EnableMetricInterceptor,
+		DisableTraceInterceptor:        !c.EnableTraceInterceptor,
+		TracerProvider:                 c.tracerProvider,
+		HashTraceKey:                   c.HashTraceKey,
+		EnableClientTrace:              c.EnableClientTrace,
+		FileURL:                        c.FileURL,
+	}
+}
+
+// transportKey identifies the clients which may share the credentials and the http transport
+func (c *Config) transportKey() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%t|%d|%d|%d|%d|%t|%t|%t", c.StorageType, c.Endpoint, c.Region, c.AccessKeyID, c.AccessKeySecret,
+		c.S3ForcePathStyle, c.SSL, c.S3HttpTimeoutSecs, c.S3HttpTransportMaxConnsPerHost, c.S3HttpTransportMaxIdleConns,
+		c.S3HttpTransportIdleConnTimeoutSecs, c.EnableMetricInterceptor, c.EnableTraceInterceptor, c.EnableClientTrace)
+}
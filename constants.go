@@ -3,6 +3,10 @@ package awos
 const (
 	StorageTypeOSS = "oss"
 	StorageTypeS3  = "s3"
+	// StorageTypeFile stores the objects in the local filesystem, see File
+	StorageTypeFile = "file"
+	// StorageTypeMemory is only used by NewMemory, New does not accept it
+	StorageTypeMemory = "memory"
 
 	MetaCompressor = "compressor"
 )
@@ -0,0 +1,61 @@
+package awos
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHangingClient(t *testing.T, storageType string) (Client, func()) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	client, err := New(&Options{
+		StorageType:      storageType,
+		AccessKeyID:      "ak",
+		AccessKeySecret:  "sk",
+		Endpoint:         server.URL,
+		Bucket:           "test",
+		Region:           "cn-north-1",
+		S3ForcePathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() {
+		close(done)
+		server.Close()
+	}
+}
+
+func TestContextClient_Cancel(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			client, closeFn := newHangingClient(t, storageType)
+			defer closeFn()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := client.GetWithContext(ctx, "key")
+			assert.Error(t, err)
+			assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+			// retries of Put must stop as soon as the context is done
+			start = time.Now()
+			err = client.PutWithContext(ctx, "key", strings.NewReader("content"), nil)
+			assert.Error(t, err)
+			assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		})
+	}
+}
@@ -0,0 +1,486 @@
+package awos
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// CredentialsRefreshWindow the credentials are refreshed this long before they expire,
+	// or at the half of their lifetime if it's shorter
+	CredentialsRefreshWindow = 5 * time.Minute
+	// FileCredentialsCheckInterval how often the file of NewFileCredentials is checked for changes
+	FileCredentialsCheckInterval = 10 * time.Second
+	// DefaultAssumeRoleDuration default lifetime of the credentials of AssumeRole
+	DefaultAssumeRoleDuration = time.Hour
+	// DefaultAliyunSTSEndpoint default endpoint of the STS of Aliyun
+	DefaultAliyunSTSEndpoint = "https://sts.aliyuncs.com"
+
+	credentialsProviderName = "awos"
+	defaultRoleSessionName  = "awos"
+)
+
+// Credentials sign the requests, SessionToken is set for the temporary credentials of STS
+type Credentials struct {
+	AccessKeyID     string `json:"accessKeyID"`
+	AccessKeySecret string `json:"accessKeySecret"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	// Expires when the credentials expire, zero means never
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// CredentialsProvider returns the credentials of the client, it's called again before the credentials expire,
+// so the credentials are rotated without recreating the Client
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (*Credentials, error)
+}
+
+// CredentialsProviderFunc is a CredentialsProvider of a function
+type CredentialsProviderFunc func(ctx context.Context) (*Credentials, error)
+
+func (f CredentialsProviderFunc) Retrieve(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+// NewStaticCredentials returns the provider of fixed credentials
+func NewStaticCredentials(accessKeyID string, accessKeySecret string, sessionToken string) CredentialsProvider {
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		return &Credentials{AccessKeyID: accessKeyID, AccessKeySecret: accessKeySecret, SessionToken: sessionToken}, nil
+	})
+}
+
+// envCredentialsNames the names of the access key id, secret and token variables, in the order they are tried
+var envCredentialsNames = [][3]string{
+	{"ALIBABA_CLOUD_ACCESS_KEY_ID", "ALIBABA_CLOUD_ACCESS_KEY_SECRET", "ALIBABA_CLOUD_SECURITY_TOKEN"},
+	{"OSS_ACCESS_KEY_ID", "OSS_ACCESS_KEY_SECRET", "OSS_SESSION_TOKEN"},
+	{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"},
+}
+
+// NewEnvCredentials returns the provider of the credentials of the environment variables of Aliyun
+// (ALIBABA_CLOUD_ACCESS_KEY_ID, OSS_ACCESS_KEY_ID) or AWS (AWS_ACCESS_KEY_ID), the first set ones are used
+func NewEnvCredentials() CredentialsProvider {
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		for _, names := range envCredentialsNames {
+			id, secret := os.Getenv(names[0]), os.Getenv(names[1])
+			if id != "" && secret != "" {
+				return &Credentials{AccessKeyID: id, AccessKeySecret: secret, SessionToken: os.Getenv(names[2])}, nil
+			}
+		}
+		return nil, errors.New("awos: no credentials in the environment")
+	})
+}
+
+// NewFileCredentials returns the provider of the credentials of a json file such as
+// {"accessKeyID": "...", "accessKeySecret": "...", "sessionToken": "...", "expires": "2006-01-02T15:04:05Z"},
+// the file is read again when it changes, e.g. when a sidecar rotates it
+func NewFileCredentials(path string) CredentialsProvider {
+	return &fileCredentials{path: path}
+}
+
+type fileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   *Credentials
+}
+
+func (f *fileCredentials) Retrieve(ctx context.Context) (*Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("awos: credentials file: %w", err)
+	}
+	if f.creds == nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+		data, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("awos: credentials file: %w", err)
+		}
+		creds := &Credentials{}
+		if err := json.Unmarshal(data, creds); err != nil {
+			return nil, fmt.Errorf("awos: credentials file %s: %w", f.path, err)
+		}
+		if creds.AccessKeyID == "" || creds.AccessKeySecret == "" {
+			return nil, fmt.Errorf("awos: credentials file %s: accessKeyID and accessKeySecret are required", f.path)
+		}
+		f.creds, f.modTime, f.size = creds, info.ModTime(), info.Size()
+	}
+	// checked again after the interval, in case the file changes before the credentials expire
+	res := *f.creds
+	if check := time.Now().Add(FileCredentialsCheckInterval); res.Expires.IsZero() || res.Expires.After(check) {
+		res.Expires = check
+	}
+	return &res, nil
+}
+
+// NewChainCredentials returns the provider of the credentials of the first provider which succeeds
+func NewChainCredentials(providers ...CredentialsProvider) CredentialsProvider {
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		errs := make([]string, 0, len(providers))
+		for _, provider := range providers {
+			creds, err := provider.Retrieve(ctx)
+			if err == nil {
+				return creds, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return nil, fmt.Errorf("awos: no credentials in the chain: %s", strings.Join(errs, "; "))
+	})
+}
+
+// AssumeRoleOptions of NewAWSAssumeRoleCredentials and NewAliyunAssumeRoleCredentials
+type AssumeRoleOptions struct {
+	// Required, the credentials calling AssumeRole
+	Source CredentialsProvider
+	// Required, the role to assume
+	RoleARN string
+	// Optional, default "awos"
+	RoleSessionName string
+	// Optional, the lifetime of the credentials, default DefaultAssumeRoleDuration
+	Duration time.Duration
+	// Optional, the policy further limiting the permissions of the role
+	Policy string
+	// Only for aws, the region of the STS
+	Region string
+	// Optional, the endpoint of the STS, e.g. a MinIO server, default the one of the region or DefaultAliyunSTSEndpoint
+	Endpoint string
+}
+
+func (o *AssumeRoleOptions) duration() time.Duration {
+	if o.Duration > 0 {
+		return o.Duration
+	}
+	return DefaultAssumeRoleDuration
+}
+
+func (o *AssumeRoleOptions) roleSessionName() string {
+	if o.RoleSessionName != "" {
+		return o.RoleSessionName
+	}
+	return defaultRoleSessionName
+}
+
+// NewAWSAssumeRoleCredentials returns the provider of the temporary credentials of AWS STS AssumeRole
+func NewAWSAssumeRoleCredentials(options AssumeRoleOptions) CredentialsProvider {
+	config := &aws.Config{
+		Region:      aws.String(options.Region),
+		Credentials: credentials.NewCredentials(&awsCredentials{cache: newCredentialsCache(options.Source)}),
+	}
+	if options.Endpoint != "" {
+		config.Endpoint = aws.String(options.Endpoint)
+	}
+	sess, sessErr := session.NewSession(config)
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		if sessErr != nil {
+			return nil, sessErr
+		}
+		input := &sts.AssumeRoleInput{
+			RoleArn:         aws.String(options.RoleARN),
+			RoleSessionName: aws.String(options.roleSessionName()),
+			DurationSeconds: aws.Int64(int64(options.duration() / time.Second)),
+		}
+		if options.Policy != "" {
+			input.Policy = aws.String(options.Policy)
+		}
+		output, err := sts.New(sess).AssumeRoleWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("awos: assume role %s: %w", options.RoleARN, err)
+		}
+		return &Credentials{
+			AccessKeyID:     aws.StringValue(output.Credentials.AccessKeyId),
+			AccessKeySecret: aws.StringValue(output.Credentials.SecretAccessKey),
+			SessionToken:    aws.StringValue(output.Credentials.SessionToken),
+			Expires:         aws.TimeValue(output.Credentials.Expiration),
+		}, nil
+	})
+}
+
+// NewAliyunAssumeRoleCredentials returns the provider of the temporary credentials of Aliyun STS AssumeRole
+func NewAliyunAssumeRoleCredentials(options AssumeRoleOptions) CredentialsProvider {
+	endpoint := options.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultAliyunSTSEndpoint
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		source, err := options.Source.Retrieve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		params := map[string]string{
+			"Action":           "AssumeRole",
+			"Version":          "2015-04-01",
+			"Format":           "JSON",
+			"RoleArn":          options.RoleARN,
+			"RoleSessionName":  options.roleSessionName(),
+			"DurationSeconds":  strconv.FormatInt(int64(options.duration()/time.Second), 10),
+			"AccessKeyId":      source.AccessKeyID,
+			"SignatureMethod":  "HMAC-SHA1",
+			"SignatureVersion": "1.0",
+			"SignatureNonce":   newNonce(),
+			"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		}
+		if options.Policy != "" {
+			params["Policy"] = options.Policy
+		}
+		if source.SessionToken != "" {
+			params["SecurityToken"] = source.SessionToken
+		}
+		query := aliyunRPCQuery(params)
+		query += "&Signature=" + aliyunPercentEncode(aliyunRPCSignature(http.MethodGet, query, source.AccessKeySecret))
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/?"+query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := credentialsHTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("awos: assume role %s: %w", options.RoleARN, err)
+		}
+		defer resp.Body.Close()
+		var output struct {
+			Code        string
+			Message     string
+			RequestId   string
+			Credentials struct {
+				AccessKeyId     string
+				AccessKeySecret string
+				SecurityToken   string
+				Expiration      time.Time
+			}
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+			return nil, fmt.Errorf("awos: assume role %s: status %d: %w", options.RoleARN, resp.StatusCode, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("awos: assume role %s: %s: %s (request id %s)", options.RoleARN, output.Code, output.Message, output.RequestId)
+		}
+		return &Credentials{
+			AccessKeyID:     output.Credentials.AccessKeyId,
+			AccessKeySecret: output.Credentials.AccessKeySecret,
+			SessionToken:    output.Credentials.SecurityToken,
+			Expires:         output.Credentials.Expiration,
+		}, nil
+	})
+}
+
+// aliyunRPCQuery returns the canonicalized query of the rpc api of Aliyun, sorted by key
+func aliyunRPCQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = aliyunPercentEncode(key) + "=" + aliyunPercentEncode(params[key])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// aliyunRPCSignature signs the canonicalized query of the rpc api of Aliyun, signature version 1.0
+func aliyunRPCSignature(method string, query string, secret string) string {
+	stringToSign := method + "&" + aliyunPercentEncode("/") + "&" + aliyunPercentEncode(query)
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// aliyunPercentEncode encodes as RFC 3986 like the rpc api of Aliyun requires
+func aliyunPercentEncode(value string) string {
+	res := url.QueryEscape(value)
+	res = strings.ReplaceAll(res, "+", "%20")
+	res = strings.ReplaceAll(res, "*", "%2A")
+	return strings.ReplaceAll(res, "%7E", "~")
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// credentialsRetrieveTimeout bounds a refresh of the credentials, e.g. a call to sts
+const credentialsRetrieveTimeout = 30 * time.Second
+
+var errCredentialsRefresh = errors.New("awos: the refresh of the credentials did not complete")
+
+// credentialsHTTPClient is the http client of the providers calling an api for the credentials
+var credentialsHTTPClient = &http.Client{Timeout: credentialsRetrieveTimeout}
+
+// credentialsCache keeps the credentials of the provider until they are about to expire
+type credentialsCache struct {
+	provider CredentialsProvider
+
+	mu        sync.Mutex
+	creds     *Credentials
+	refreshAt time.Time
+	// refreshing is closed when the refresh in flight is done, err is the error of the last refresh
+	refreshing chan struct{}
+	err        error
+}
+
+func newCredentialsCache(provider CredentialsProvider) *credentialsCache {
+	return &credentialsCache{provider: provider}
+}
+
+// get returns the cached credentials, they are retrieved again if they are about to expire.
+// A single caller refreshes them without holding the lock, the others use the cached credentials meanwhile
+// if they are still valid, or wait for the refresh. If the refresh fails, the cached credentials are used
+// until they actually expire.
+func (c *credentialsCache) get(ctx context.Context) (*Credentials, error) {
+	c.mu.Lock()
+	now := time.Now()
+	if c.creds != nil && !c.needsRefresh(now) || c.refreshing != nil && c.valid(now) {
+		creds := c.creds
+		c.mu.Unlock()
+		return creds, nil
+	}
+	if done := c.refreshing; done != nil {
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.err != nil && !c.valid(time.Now()) {
+			return nil, c.err
+		}
+		return c.creds, nil
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	// for the waiters if the provider panics
+	c.err = errCredentialsRefresh
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.refreshing = nil
+		c.mu.Unlock()
+		close(done)
+	}()
+
+	retrieveCtx, cancel := context.WithTimeout(ctx, credentialsRetrieveTimeout)
+	defer cancel()
+	creds, err := c.provider.Retrieve(retrieveCtx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err != nil {
+		if c.valid(now) {
+			return c.creds, nil
+		}
+		return nil, err
+	}
+	c.creds = creds
+	c.refreshAt = time.Time{}
+	if !creds.Expires.IsZero() {
+		window := CredentialsRefreshWindow
+		if half := creds.Expires.Sub(now) / 2; half < window {
+			window = half
+		}
+		c.refreshAt = creds.Expires.Add(-window)
+	}
+	return creds, nil
+}
+
+// valid reports whether the cached credentials are not expired
+func (c *credentialsCache) valid(now time.Time) bool {
+	return c.creds != nil && (c.creds.Expires.IsZero() || now.Before(c.creds.Expires))
+}
+
+func (c *credentialsCache) needsRefresh(now time.Time) bool {
+	return !c.refreshAt.IsZero() && !now.Before(c.refreshAt)
+}
+
+func (c *credentialsCache) expired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.creds == nil || c.needsRefresh(time.Now())
+}
+
+// awsCredentials is the credentials.Provider of s3
+type awsCredentials struct {
+	cache *credentialsCache
+}
+
+func (p *awsCredentials) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+func (p *awsCredentials) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	creds, err := p.cache.get(ctx)
+	if err != nil {
+		return credentials.Value{ProviderName: credentialsProviderName}, err
+	}
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.AccessKeySecret,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    credentialsProviderName,
+	}, nil
+}
+
+func (p *awsCredentials) IsExpired() bool {
+	return p.cache.expired()
+}
+
+// ossCredentials is the oss.CredentialsProvider of oss, which is called for every request and can't fail,
+// without credentials the request is rejected by oss
+type ossCredentials struct {
+	cache *credentialsCache
+}
+
+func (p *ossCredentials) GetCredentials() oss.Credentials {
+	creds, err := p.cache.get(context.Background())
+	if err != nil {
+		return ossCredentialsValue{&Credentials{}}
+	}
+	return ossCredentialsValue{creds}
+}
+
+// ossCredentialsValue is the oss.Credentials of Credentials
+type ossCredentialsValue struct {
+	*Credentials
+}
+
+func (c ossCredentialsValue) GetAccessKeyID() string {
+	return c.AccessKeyID
+}
+
+func (c ossCredentialsValue) GetAccessKeySecret() string {
+	return c.AccessKeySecret
+}
+
+func (c ossCredentialsValue) GetSecurityToken() string {
+	return c.SessionToken
+}
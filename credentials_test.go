@@ -0,0 +1,263 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRotatingCredentials returns credentials ak-1, ak-2... each expiring after ttl
+func newRotatingCredentials(ttl time.Duration) (CredentialsProvider, *int32) {
+	var calls int32
+	return CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		n := atomic.AddInt32(&calls, 1)
+		return &Credentials{
+			AccessKeyID:     fmt.Sprintf("ak-%d", n),
+			AccessKeySecret: "sk",
+			SessionToken:    fmt.Sprintf("token-%d", n),
+			Expires:         time.Now().Add(ttl),
+		}, nil
+	}), &calls
+}
+
+func TestCredentialsCache(t *testing.T) {
+	provider, calls := newRotatingCredentials(100 * time.Millisecond)
+	cache := newCredentialsCache(provider)
+	creds, err := cache.get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ak-1", creds.AccessKeyID)
+	creds, _ = cache.get(context.Background())
+	assert.Equal(t, "ak-1", creds.AccessKeyID)
+	assert.False(t, cache.expired())
+
+	// refreshed at the half of the lifetime
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, cache.expired())
+	creds, _ = cache.get(context.Background())
+	assert.Equal(t, "ak-2", creds.AccessKeyID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// the cached credentials are used until they expire if the refresh fails
+	var fail int32
+	cache = newCredentialsCache(CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, errors.New("sts unavailable")
+		}
+		return provider.Retrieve(ctx)
+	}))
+	_, err = cache.get(context.Background())
+	assert.NoError(t, err)
+	atomic.StoreInt32(&fail, 1)
+	time.Sleep(60 * time.Millisecond)
+	_, err = cache.get(context.Background())
+	assert.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, err = cache.get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCredentialsCache_Refreshing(t *testing.T) {
+	provider, calls := newRotatingCredentials(100 * time.Millisecond)
+	block := make(chan struct{})
+	var blocking int32
+	cache := newCredentialsCache(CredentialsProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		if atomic.LoadInt32(&blocking) == 1 {
+			select {
+			case <-block:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return provider.Retrieve(ctx)
+	}))
+	_, err := cache.get(context.Background())
+	assert.NoError(t, err)
+
+	// a hung refresh doesn't block the other callers while the cached credentials are valid
+	atomic.StoreInt32(&blocking, 1)
+	time.Sleep(60 * time.Millisecond)
+	refreshed := make(chan *Credentials)
+	go func() {
+		creds, _ := cache.get(context.Background())
+		refreshed <- creds
+	}()
+	for started := false; !started; time.Sleep(time.Millisecond) {
+		cache.mu.Lock()
+		started = cache.refreshing != nil
+		cache.mu.Unlock()
+	}
+	creds, err := cache.get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ak-1", creds.AccessKeyID)
+
+	// once they expire, the callers wait for the refresh in flight, up to their context
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	waited := make(chan *Credentials)
+	go func() {
+		creds, _ := cache.get(context.Background())
+		waited <- creds
+	}()
+	close(block)
+	assert.Equal(t, "ak-2", (<-refreshed).AccessKeyID)
+	assert.Equal(t, "ak-2", (<-waited).AccessKeyID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"accessKeyID": "ak-1", "accessKeySecret": "sk-1"}`), 0600))
+	provider := NewFileCredentials(path)
+	creds, err := provider.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ak-1", creds.AccessKeyID)
+	assert.WithinDuration(t, time.Now().Add(FileCredentialsCheckInterval), creds.Expires, time.Second)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"accessKeyID": "ak-2", "accessKeySecret": "sk-2", "sessionToken": "token"}`), 0600))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	creds, err = provider.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ak-2", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+
+	_, err = NewFileCredentials(filepath.Join(t.TempDir(), "missing.json")).Retrieve(context.Background())
+	assert.Error(t, err)
+}
+
+func TestChainCredentials(t *testing.T) {
+	for _, names := range envCredentialsNames {
+		t.Setenv(names[0], "")
+		t.Setenv(names[1], "")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "aws-ak")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "aws-sk")
+	creds, err := NewChainCredentials(NewFileCredentials("missing.json"), NewEnvCredentials()).Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "aws-ak", creds.AccessKeyID)
+
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "ali-ak")
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "ali-sk")
+	creds, err = NewEnvCredentials().Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ali-ak", creds.AccessKeyID)
+
+	_, err = NewChainCredentials(NewFileCredentials("missing.json")).Retrieve(context.Background())
+	assert.ErrorContains(t, err, "missing.json")
+}
+
+func TestAliyunAssumeRoleCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		params := make(map[string]string)
+		for key := range query {
+			if key != "Signature" {
+				params[key] = query.Get(key)
+			}
+		}
+		if query.Get("Signature") != aliyunRPCSignature(http.MethodGet, aliyunRPCQuery(params), "sk") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code": "SignatureDoesNotMatch", "Message": "signature", "RequestId": "req-1"}`)
+			return
+		}
+		assert.Equal(t, "AssumeRole", params["Action"])
+		assert.Equal(t, "acs:ram::1:role/oss", params["RoleArn"])
+		assert.Equal(t, "900", params["DurationSeconds"])
+		fmt.Fprint(w, `{"Credentials": {"AccessKeyId": "STS.ak", "AccessKeySecret": "sts-sk", "SecurityToken": "token", "Expiration": "2030-01-01T00:00:00Z"}}`)
+	}))
+	defer server.Close()
+
+	options := AssumeRoleOptions{
+		Source:   NewStaticCredentials("ak", "sk", ""),
+		RoleARN:  "acs:ram::1:role/oss",
+		Duration: 15 * time.Minute,
+		Endpoint: server.URL,
+	}
+	creds, err := NewAliyunAssumeRoleCredentials(options).Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &Credentials{AccessKeyID: "STS.ak", AccessKeySecret: "sts-sk", SessionToken: "token", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}, creds)
+
+	options.Source = NewStaticCredentials("ak", "wrong", "")
+	_, err = NewAliyunAssumeRoleCredentials(options).Retrieve(context.Background())
+	assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+
+	assert.Equal(t, "a%20b%2A~%2F", aliyunPercentEncode("a b*~/"))
+}
+
+func TestAWSAssumeRoleCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		assert.Equal(t, "AssumeRole", form.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::1:role/s3", form.Get("RoleArn"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=ak/")
+		fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials>
+<AccessKeyId>ASIA</AccessKeyId><SecretAccessKey>sts-sk</SecretAccessKey><SessionToken>token</SessionToken>
+<Expiration>2030-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+	}))
+	defer server.Close()
+
+	creds, err := NewAWSAssumeRoleCredentials(AssumeRoleOptions{
+		Source:   NewStaticCredentials("ak", "sk", ""),
+		RoleARN:  "arn:aws:iam::1:role/s3",
+		Region:   "cn-north-1",
+		Endpoint: server.URL,
+	}).Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIA", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+	assert.True(t, creds.Expires.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestCredentialsProvider_Rotation(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			var mu sync.Mutex
+			var authorizations, tokens []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				authorizations = append(authorizations, r.Header.Get("Authorization"))
+				tokens = append(tokens, r.Header.Get("X-Amz-Security-Token")+r.Header.Get("X-Oss-Security-Token"))
+				mu.Unlock()
+				fmt.Fprint(w, "content")
+			}))
+			defer server.Close()
+
+			provider, _ := newRotatingCredentials(100 * time.Millisecond)
+			client, err := New(&Options{
+				StorageType:         storageType,
+				CredentialsProvider: provider,
+				Endpoint:            server.URL,
+				Bucket:              "test",
+				Region:              "cn-north-1",
+				S3ForcePathStyle:    true,
+			})
+			assert.NoError(t, err)
+			_, err = client.Get("key")
+			assert.NoError(t, err)
+			time.Sleep(60 * time.Millisecond)
+			_, err = client.Get("key")
+			assert.NoError(t, err)
+
+			assert.Len(t, authorizations, 2)
+			assert.True(t, strings.Contains(authorizations[0], "ak-1"), authorizations[0])
+			assert.True(t, strings.Contains(authorizations[1], "ak-2"), authorizations[1])
+			assert.Equal(t, []string{"token-1", "token-2"}, tokens)
+		})
+	}
+}
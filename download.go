@@ -0,0 +1,152 @@
+package awos
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+)
+
+// downloadObject describes the object to download, checksum is compared with the sum of hash when both are set
+type downloadObject struct {
+	size     int64
+	hash     hash.Hash
+	checksum string
+	sum      func(h hash.Hash) string
+}
+
+func getDownloadOptions(options []DownloadOptions) *downloadOptions {
+	downloadOpts := DefaultDownloadOptions()
+	for _, opt := range options {
+		opt(downloadOpts)
+	}
+	if downloadOpts.partSize <= 0 {
+		downloadOpts.partSize = DefaultPartSize
+	}
+	if downloadOpts.concurrency <= 0 {
+		downloadOpts.concurrency = 1
+	}
+	if downloadOpts.partAttempts == 0 {
+		downloadOpts.partAttempts = 1
+	}
+	return downloadOpts
+}
+
+// downloadRanges fetches the object range by range, at most opts.concurrency ranges at the same time,
+// and writes them to w. Every range is retried on its own by policy with opts.partAttempts.
+// The ranges are hashed in order, so at most 2*concurrency ranges are kept in memory.
+func downloadRanges(ctx context.Context, object *downloadObject, w io.WriterAt, opts *downloadOptions, policy *RetryPolicy,
+	fetch func(ctx context.Context, offset int64, length int64) (io.ReadCloser, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	partPolicy := policy.withAttempts(int(opts.partAttempts))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		workers  = make(chan struct{}, opts.concurrency)
+		window   = make(chan struct{}, 2*opts.concurrency)
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		done     = make(map[int64][]byte)
+		hashed   int64
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+			mu.Lock()
+			cond.Broadcast()
+			mu.Unlock()
+		})
+	}
+
+	parts := (object.size + opts.partSize - 1) / opts.partSize
+	// the hasher consumes the downloaded ranges in order and releases the window
+	hasherDone := make(chan struct{})
+	go func() {
+		defer close(hasherDone)
+		mu.Lock()
+		defer mu.Unlock()
+		for hashed < parts {
+			data, ok := done[hashed]
+			for !ok && ctx.Err() == nil {
+				cond.Wait()
+				data, ok = done[hashed]
+			}
+			if !ok {
+				return
+			}
+			delete(done, hashed)
+			if object.hash != nil {
+				object.hash.Write(data)
+			}
+			hashed++
+			<-window
+		}
+	}()
+
+	for part := int64(0); part < parts; part++ {
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() == nil {
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			setErr(ctx.Err())
+			break
+		}
+
+		offset := part * opts.partSize
+		length := opts.partSize
+		if offset+length > object.size {
+			length = object.size - offset
+		}
+		wg.Add(1)
+		go func(part, offset, length int64) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			var data []byte
+			err := partPolicy.do(ctx, true, func(ctx context.Context) error {
+				body, err := fetch(ctx, offset, length)
+				if err != nil {
+					return err
+				}
+				defer body.Close()
+				data = make([]byte, length)
+				_, err = io.ReadFull(body, data)
+				return err
+			})
+			if err == nil {
+				_, err = w.WriteAt(data, offset)
+			}
+			if err != nil {
+				setErr(fmt.Errorf("download range %d-%d failed: %w", offset, offset+length-1, err))
+				return
+			}
+			mu.Lock()
+			done[part] = data
+			cond.Broadcast()
+			mu.Unlock()
+		}(part, offset, length)
+	}
+	wg.Wait()
+	<-hasherDone
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if object.hash != nil && object.checksum != "" {
+		if sum := object.sum(object.hash); sum != object.checksum {
+			return fmt.Errorf("checksum mismatch, expected:%s, got:%s", object.checksum, sum)
+		}
+	}
+	return nil
+}
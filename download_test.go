@@ -0,0 +1,80 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferWriterAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *bufferWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int(off)+len(p) > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, int(off)+len(p)-len(b.buf))...)
+	}
+	copy(b.buf[off:], p)
+	return len(p), nil
+}
+
+func newMD5Object(content []byte, checksum string) *downloadObject {
+	return &downloadObject{
+		size:     int64(len(content)),
+		hash:     md5.New(),
+		checksum: checksum,
+		sum: func(h hash.Hash) string {
+			return hex.EncodeToString(h.Sum(nil))
+		},
+	}
+}
+
+func TestDownloadRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	sum := md5.Sum(content)
+
+	var failed int32
+	fetch := func(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+		// the first attempt of the second range fails and must be retried
+		if offset == 7 && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return nil, errors.New("connection reset by peer")
+		}
+		return ioutil.NopCloser(bytes.NewReader(content[offset : offset+length])), nil
+	}
+
+	w := &bufferWriterAt{}
+	opts := &downloadOptions{partSize: 7, concurrency: 3, partAttempts: 2}
+	err := downloadRanges(context.Background(), newMD5Object(content, hex.EncodeToString(sum[:])), w, opts, nil, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, content, w.buf)
+
+	err = downloadRanges(context.Background(), newMD5Object(content, "bad"), &bufferWriterAt{}, opts, nil, fetch)
+	assert.EqualError(t, err, "checksum mismatch, expected:bad, got:"+hex.EncodeToString(sum[:]))
+}
+
+func TestDownloadRanges_Error(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 100)
+	fetch := func(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+		if offset == 50 {
+			return nil, errors.New("internal error")
+		}
+		return ioutil.NopCloser(bytes.NewReader(content[offset : offset+length])), nil
+	}
+
+	opts := &downloadOptions{partSize: 10, concurrency: 2, partAttempts: 1}
+	err := downloadRanges(context.Background(), newMD5Object(content, ""), &bufferWriterAt{}, opts, nil, fetch)
+	assert.EqualError(t, err, "download range 50-59 failed: internal error")
+}
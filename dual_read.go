@@ -0,0 +1,253 @@
+package awos
+
+import (
+	"context"
+	"io"
+)
+
+var _ Client = (*DualReadClient)(nil)
+
+// DualReadClient is used while objects are moved to a new shard layout, see Rebalancer.
+// Reads look in Primary (the new layout) first and fall back to Fallback (the old layout) if the object is not found,
+// writes only go to Primary, and deletes go to both so that deleted objects don't come back from Fallback.
+type DualReadClient struct {
+	Primary  Client
+	Fallback Client
+}
+
+// NewDualReadClient creates a DualReadClient, both clients are usually created by New with different Shards
+func NewDualReadClient(primary Client, fallback Client) *DualReadClient {
+	return &DualReadClient{Primary: primary, Fallback: fallback}
+}
+
+func (d *DualReadClient) Get(key string, options ...GetOptions) (string, error) {
+	return d.GetWithContext(context.Background(), key, options...)
+}
+
+func (d *DualReadClient) GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := d.GetBytesWithContext(ctx, key, options...)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (d *DualReadClient) GetBytes(key string, options ...GetOptions) ([]byte, error) {
+	return d.GetBytesWithContext(context.Background(), key, options...)
+}
+
+func (d *DualReadClient) GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	data, err := d.Primary.GetBytesWithContext(ctx, key, options...)
+	if !IsNotFound(err) && (err != nil || data != nil) {
+		return data, err
+	}
+	return d.Fallback.GetBytesWithContext(ctx, key, options...)
+}
+
+func (d *DualReadClient) GetAsReader(key string, options ...GetOptions) (io.ReadCloser, error) {
+	return d.GetAsReaderWithContext(context.Background(), key, options...)
+}
+
+func (d *DualReadClient) GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	reader, err := d.Primary.GetAsReaderWithContext(ctx, key, options...)
+	if !IsNotFound(err) && (err != nil || reader != nil) {
+		return reader, err
+	}
+	return d.Fallback.GetAsReaderWithContext(ctx, key, options...)
+}
+
+func (d *DualReadClient) GetWithMetaGZIP(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return d.GetWithMetaGZIPWithContext(context.Background(), key, attributes, options...)
+}
+
+func (d *DualReadClient) GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	reader, meta, err := d.Primary.GetWithMetaGZIPWithContext(ctx, key, attributes, options...)
+	if !IsNotFound(err) && (err != nil || reader != nil) {
+		return reader, meta, err
+	}
+	return d.Fallback.GetWithMetaGZIPWithContext(ctx, key, attributes, options...)
+}
+
+func (d *DualReadClient) GetWithMeta(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return d.GetWithMetaWithContext(context.Background(), key, attributes, options...)
+}
+
+func (d *DualReadClient) GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	reader, meta, err := d.Primary.GetWithMetaWithContext(ctx, key, attributes, options...)
+	if !IsNotFound(err) && (err != nil || reader != nil) {
+		return reader, meta, err
+	}
+	return d.Fallback.GetWithMetaWithContext(ctx, key, attributes, options...)
+}
+
+func (d *DualReadClient) Put(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return d.PutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (d *DualReadClient) PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return d.Primary.PutWithContext(ctx, key, reader, meta, options...)
+}
+
+func (d *DualReadClient) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return d.CompressAndPutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (d *DualReadClient) CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return d.Primary.CompressAndPutWithContext(ctx, key, reader, meta, options...)
+}
+
+func (d *DualReadClient) Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return d.UploadWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (d *DualReadClient) UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return d.Primary.UploadWithContext(ctx, key, reader, meta, options...)
+}
+
+func (d *DualReadClient) Del(key string) error {
+	return d.DelWithContext(context.Background(), key)
+}
+
+func (d *DualReadClient) DelWithContext(ctx context.Context, key string) error {
+	if err := d.Primary.DelWithContext(ctx, key); err != nil {
+		return err
+	}
+	return d.Fallback.DelWithContext(ctx, key)
+}
+
+func (d *DualReadClient) DelMulti(keys []string) error {
+	return d.DelMultiWithContext(context.Background(), keys)
+}
+
+func (d *DualReadClient) DelMultiWithContext(ctx context.Context, keys []string) error {
+	if err := d.Primary.DelMultiWithContext(ctx, keys); err != nil {
+		return err
+	}
+	return d.Fallback.DelMultiWithContext(ctx, keys)
+}
+
+func (d *DualReadClient) Head(key string, attributes []string) (map[string]string, error) {
+	return d.HeadWithContext(context.Background(), key, attributes)
+}
+
+func (d *DualReadClient) HeadWithContext(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	meta, err := d.Primary.HeadWithContext(ctx, key, attributes)
+	if !IsNotFound(err) && (err != nil || meta != nil) {
+		return meta, err
+	}
+	return d.Fallback.HeadWithContext(ctx, key, attributes)
+}
+
+func (d *DualReadClient) ListObject(key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return d.ListObjectWithContext(context.Background(), key, prefix, marker, maxKeys, delimiter)
+}
+
+// ListObjectWithContext merges the keys of both clients
+func (d *DualReadClient) ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	result, err := d.List(ctx, ListInput{Key: key, Prefix: prefix, Marker: marker, MaxKeys: maxKeys, Delimiter: delimiter})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(result.Objects))
+	for _, v := range result.Objects {
+		keys = append(keys, v.Key)
+	}
+	return keys, nil
+}
+
+// List merges the pages of both clients in key order, the NextContinuationToken can only be used with DualReadClient
+func (d *DualReadClient) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	clients := map[string]Client{"0-primary": d.Primary, "1-fallback": d.Fallback}
+	return listShards(ctx, []string{"0-primary", "1-fallback"}, input, func(ctx context.Context, bucket string, in ListInput) (*ListResult, error) {
+		in.Key = input.Key
+		in.AllShards = input.AllShards
+		return clients[bucket].List(ctx, in)
+	})
+}
+
+func (d *DualReadClient) SignURL(key string, expired int64, options ...SignOptions) (string, error) {
+	client, err := d.locate(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	return client.SignURL(key, expired, options...)
+}
+
+func (d *DualReadClient) GetAndDecompress(key string) (string, error) {
+	return d.GetAndDecompressWithContext(context.Background(), key)
+}
+
+func (d *DualReadClient) GetAndDecompressWithContext(ctx context.Context, key string) (string, error) {
+	data, err := d.Primary.GetAndDecompressWithContext(ctx, key)
+	if !IsNotFound(err) {
+		if err != nil || data != "" {
+			return data, err
+		}
+		// "" is returned for both empty and missing objects if NotFoundAsNil is set
+		ok, err := d.Primary.ExistsWithContext(ctx, key)
+		if err != nil || ok {
+			return data, err
+		}
+	}
+	return d.Fallback.GetAndDecompressWithContext(ctx, key)
+}
+
+func (d *DualReadClient) GetAndDecompressAsReader(key string) (io.ReadCloser, error) {
+	return d.GetAndDecompressAsReaderWithContext(context.Background(), key)
+}
+
+func (d *DualReadClient) GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := d.Primary.GetAndDecompressAsReaderWithContext(ctx, key)
+	if !IsNotFound(err) && (err != nil || reader != nil) {
+		return reader, err
+	}
+	return d.Fallback.GetAndDecompressAsReaderWithContext(ctx, key)
+}
+
+func (d *DualReadClient) Range(key string, offset int64, length int64) (io.ReadCloser, error) {
+	return d.RangeWithContext(context.Background(), key, offset, length)
+}
+
+func (d *DualReadClient) RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	client, err := d.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return client.RangeWithContext(ctx, key, offset, length)
+}
+
+func (d *DualReadClient) Download(key string, w io.WriterAt, options ...DownloadOptions) error {
+	return d.DownloadWithContext(context.Background(), key, w, options...)
+}
+
+func (d *DualReadClient) DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error {
+	client, err := d.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	return client.DownloadWithContext(ctx, key, w, options...)
+}
+
+func (d *DualReadClient) Exists(key string) (bool, error) {
+	return d.ExistsWithContext(context.Background(), key)
+}
+
+func (d *DualReadClient) ExistsWithContext(ctx context.Context, key string) (bool, error) {
+	ok, err := d.Primary.ExistsWithContext(ctx, key)
+	if err != nil || ok {
+		return ok, err
+	}
+	return d.Fallback.ExistsWithContext(ctx, key)
+}
+
+// locate returns Primary if the object exists in it, otherwise Fallback
+func (d *DualReadClient) locate(ctx context.Context, key string) (Client, error) {
+	ok, err := d.Primary.ExistsWithContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return d.Primary, nil
+	}
+	return d.Fallback, nil
+}
@@ -0,0 +1,31 @@
+package awos
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualReadClient_GetAndDecompressAsReader(t *testing.T) {
+	primary, fallback := NewMemory(), NewMemory()
+	client := NewDualReadClient(primary, fallback)
+	content := strings.Repeat("content", 100)
+	assert.NoError(t, primary.CompressAndPut("new", strings.NewReader(content), nil, PutWithCompressType("zstd")))
+	assert.NoError(t, fallback.CompressAndPut("old", strings.NewReader(content), nil, PutWithCompressType("zstd")))
+
+	for _, key := range []string{"new", "old"} {
+		r, err := client.GetAndDecompressAsReader(key)
+		assert.NoError(t, err)
+		// the stream of the client which has the object
+		assert.IsType(t, &decodedBody{}, r)
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+		assert.Equal(t, content, string(data))
+	}
+
+	_, err := client.GetAndDecompressAsReader("missing")
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,70 @@
+package awos
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound the object does not exist
+	ErrNotFound = errors.New("awos: object not found")
+	// ErrAccessDenied the credentials are invalid or not allowed to access the object
+	ErrAccessDenied = errors.New("awos: access denied")
+	// ErrPreconditionFailed a condition such as If-Match is not met
+	ErrPreconditionFailed = errors.New("awos: precondition failed")
+	// ErrThrottled the request rate is too high
+	ErrThrottled = errors.New("awos: throttled")
+)
+
+// Error is returned by every failed request to oss/s3, use errors.Is with ErrNotFound etc. to check the kind
+type Error struct {
+	// StorageType oss or s3
+	StorageType string
+	// Op the operation of the sdk, e.g. GetObject
+	Op         string
+	Bucket     string
+	Key        string
+	StatusCode int
+	// Code the error code of the service, e.g. NoSuchKey
+	Code      string
+	RequestID string
+	// Err the original error of the sdk
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("awos: %s %s %s/%s failed, status:%d, code:%s, reqId:%s: %v",
+		e.StorageType, e.Op, e.Bucket, e.Key, e.StatusCode, e.Code, e.RequestID, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the kind of the sentinel target
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		if e.Code == "NoSuchBucket" {
+			return false
+		}
+		return e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey" || e.Code == "NotFound"
+	case ErrAccessDenied:
+		return e.StatusCode == http.StatusForbidden || e.Code == "AccessDenied"
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed || e.Code == "PreconditionFailed"
+	case ErrThrottled:
+		switch e.Code {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequests", "QpsLimitExceeded":
+			return true
+		}
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsNotFound reports whether the object does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
@@ -0,0 +1,106 @@
+package awos
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newErrorClient returns a client whose requests fail with the status and code of the key, e.g. "404-NoSuchKey"
+func newErrorClient(t *testing.T, storageType string, notFoundAsNil bool) (Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		var status int
+		var code string
+		_, _ = fmt.Sscanf(strings.Replace(key, "-", " ", 1), "%d %s", &status, &code)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("X-Amz-Request-Id", "req-1")
+		w.Header().Set("X-Oss-Request-Id", "req-1")
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			fmt.Fprintf(w, "<Error><Code>%s</Code><Message>error</Message><RequestId>req-1</RequestId></Error>", code)
+		}
+	}))
+	client, err := New(&Options{
+		StorageType:      storageType,
+		AccessKeyID:      "ak",
+		AccessKeySecret:  "sk",
+		Endpoint:         server.URL,
+		Bucket:           "test",
+		Region:           "cn-north-1",
+		S3ForcePathStyle: true,
+		NotFoundAsNil:    notFoundAsNil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
+
+func TestError(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			client, closeFn := newErrorClient(t, storageType, false)
+			defer closeFn()
+
+			_, err := client.Get("404-NoSuchKey")
+			assert.True(t, errors.Is(err, ErrNotFound))
+			var awosErr *Error
+			assert.True(t, errors.As(err, &awosErr))
+			assert.Equal(t, storageType, awosErr.StorageType)
+			assert.Equal(t, "GetObject", awosErr.Op)
+			assert.Equal(t, "test", awosErr.Bucket)
+			assert.Equal(t, "404-NoSuchKey", awosErr.Key)
+			assert.Equal(t, http.StatusNotFound, awosErr.StatusCode)
+			assert.Equal(t, "NoSuchKey", awosErr.Code)
+			assert.Equal(t, "req-1", awosErr.RequestID)
+
+			_, err = client.Head("404-NoSuchKey", nil)
+			assert.True(t, IsNotFound(err))
+			_, err = client.Range("404-NoSuchKey", 0, 1)
+			assert.True(t, IsNotFound(err))
+			ok, err := client.Exists("404-NoSuchKey")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			_, err = client.Get("403-AccessDenied")
+			assert.True(t, errors.Is(err, ErrAccessDenied))
+			assert.False(t, IsNotFound(err))
+			_, err = client.Get("412-PreconditionFailed")
+			assert.True(t, errors.Is(err, ErrPreconditionFailed))
+			_, err = client.Get("503-SlowDown")
+			assert.True(t, errors.Is(err, ErrThrottled))
+		})
+	}
+}
+
+func TestError_NotFoundAsNil(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			client, closeFn := newErrorClient(t, storageType, true)
+			defer closeFn()
+
+			res, err := client.Get("404-NoSuchKey")
+			assert.NoError(t, err)
+			assert.Equal(t, "", res)
+			meta, err := client.Head("404-NoSuchKey", nil)
+			assert.NoError(t, err)
+			assert.Nil(t, meta)
+
+			_, err = client.Get("403-AccessDenied")
+			assert.True(t, errors.Is(err, ErrAccessDenied))
+		})
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	assert.False(t, errors.Is(&Error{StatusCode: http.StatusNotFound, Code: "NoSuchBucket"}, ErrNotFound))
+	assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", &Error{Code: "NoSuchKey"}), ErrNotFound))
+	assert.True(t, errors.Is(&Error{StatusCode: http.StatusTooManyRequests}, ErrThrottled))
+	assert.False(t, IsNotFound(errors.New("awos: object not found")))
+}
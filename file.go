@@ -0,0 +1,813 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var _ Client = (*File)(nil)
+
+const (
+	// fileMetaMagic ends the files written by Put, after the json of the metadata and its length
+	fileMetaMagic = "\x00awos-meta/1"
+	// fileTempPrefix is the prefix of the temporary files renamed to the objects by Put
+	fileTempPrefix = ".awos-tmp-"
+)
+
+// File is a Client storing the objects in the local filesystem, e.g. for private deployments and local development without MinIO.
+// Every bucket is a directory under Root, an object is a file at its key with the metadata and content headers
+// stored after the content, so that Put replaces both at once. Keys are paths relative to the bucket, so "a" and "a/b"
+// can't both exist.
+type File struct {
+	// Root the directory of the buckets
+	Root string
+	// ShardsBucket shard => bucket name, keyed by the last character of the key when ShardRouter is nil
+	ShardsBucket map[string]string
+	ShardRouter  ShardRouter
+	BucketName   string
+	compressor   Compressor
+	cfg          *Config
+	interceptors []interceptor
+}
+
+// fileMeta is the metadata stored after the content of an object
+type fileMeta struct {
+	Meta               map[string]string `json:"meta,omitempty"`
+	ContentType        string            `json:"contentType"`
+	ContentEncoding    *string           `json:"contentEncoding,omitempty"`
+	ContentDisposition *string           `json:"contentDisposition,omitempty"`
+	CacheControl       *string           `json:"cacheControl,omitempty"`
+	Expires            *time.Time        `json:"expires,omitempty"`
+	ETag               string            `json:"etag"`
+}
+
+func newFile(cfg *Config, options *Options) (Client, error) {
+	if options.Endpoint == "" {
+		return nil, errors.New("awos: Endpoint, the root directory, is required for file")
+	}
+	fileClient := &File{Root: options.Endpoint, cfg: cfg, interceptors: getInterceptors(cfg)}
+	if len(options.Shards) > 0 {
+		buckets := make(map[string]string)
+		for _, v := range options.Shards {
+			buckets[v] = options.Bucket + "-" + v
+		}
+		fileClient.ShardsBucket = buckets
+		fileClient.ShardRouter = getShardRouter(options)
+	} else {
+		fileClient.BucketName = options.Bucket
+	}
+	if options.EnableCompressor {
+		comp, err := getCompressor(options.CompressType)
+		if err != nil {
+			return nil, err
+		}
+		fileClient.cfg.EnableCompressor = options.EnableCompressor
+		fileClient.cfg.CompressType = options.CompressType
+		fileClient.cfg.CompressLimit = options.CompressLimit
+		fileClient.compressor = comp
+	}
+	return fileClient, nil
+}
+
+func (f *File) getBucket(key string) (string, error) {
+	if len(f.ShardsBucket) > 0 {
+		shard, err := routeShard(f.ShardRouter, key)
+		if err != nil {
+			return "", err
+		}
+		bucketName := f.ShardsBucket[shard]
+		if bucketName == "" {
+			return "", errors.New("shards can't find bucket")
+		}
+		return bucketName, nil
+	}
+	return f.BucketName, nil
+}
+
+// filePath returns the path of the object, keys escaping the bucket or clashing with the files of awos are rejected
+func filePath(root string, bucket string, key string) (string, error) {
+	if err := checkFileKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, bucket, filepath.FromSlash(key)), nil
+}
+
+func checkFileKey(key string) error {
+	if key == "" || strings.ContainsRune(key, 0) {
+		return fmt.Errorf("awos: key %q is not supported by the file storage", key)
+	}
+	for _, name := range strings.Split(key, "/") {
+		if name == "" || name == "." || name == ".." || strings.HasPrefix(name, fileTempPrefix) {
+			return fmt.Errorf("awos: key %q is not supported by the file storage", key)
+		}
+	}
+	return nil
+}
+
+func (f *File) notFoundAsNil() bool {
+	return f.cfg != nil && f.cfg.NotFoundAsNil
+}
+
+// do runs a request of op through the interceptors, the error is converted to *Error.
+// The filesystem is not retried.
+func (f *File) do(ctx context.Context, op string, bucket string, key string, fn func(ctx context.Context) error) error {
+	info := &requestInfo{StorageType: StorageTypeFile, Op: op, Bucket: bucket, Shard: f.shardOf(bucket), Key: key}
+	return runInterceptors(ctx, f.interceptors, info, func(err error) error {
+		return wrapFileError(op, bucket, key, err)
+	}, func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(ctx)
+	})
+}
+
+// shardOf returns the shard of the bucket, "" without shards
+func (f *File) shardOf(bucket string) string {
+	for shard, name := range f.ShardsBucket {
+		if name == bucket {
+			return shard
+		}
+	}
+	return ""
+}
+
+// wrapFileError converts the error of the filesystem to *Error, the context errors are returned as is
+func wrapFileError(op string, bucket string, key string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	res := &Error{StorageType: StorageTypeFile, Op: op, Bucket: bucket, Key: key, Err: err}
+	switch {
+	case os.IsNotExist(err):
+		res.StatusCode, res.Code = http.StatusNotFound, "NoSuchKey"
+	case os.IsPermission(err):
+		res.StatusCode, res.Code = http.StatusForbidden, "AccessDenied"
+	}
+	return res
+}
+
+// errIsDir is returned for the keys of directories, they are not objects
+func errIsDir(name string) error {
+	return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// fileObject is an opened object, it reads the content without the metadata
+type fileObject struct {
+	*io.SectionReader
+	file    *os.File
+	meta    *fileMeta
+	modTime time.Time
+}
+
+func (o *fileObject) Close() error {
+	return o.file.Close()
+}
+
+// headers returns the metadata of the object for getS3Meta
+func (o *fileObject) headers(options []GetOptions) map[string]*string {
+	return objectHeaders(o.meta.Meta, o.Size(), o.meta.ContentType, o.meta.ContentEncoding, o.meta.ContentDisposition, options)
+}
+
+// openFileObject opens the object and reads its metadata, a file not written by Put is an object without metadata
+func openFileObject(root string, bucket string, key string) (*fileObject, error) {
+	name, err := filePath(root, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, errIsDir(name)
+	}
+	meta, size, err := readFileMeta(file, stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileObject{SectionReader: io.NewSectionReader(file, 0, size), file: file, meta: meta, modTime: stat.ModTime()}, nil
+}
+
+// readFileMeta reads the metadata at the end of the file of size bytes and returns it with the size of the content
+func readFileMeta(file *os.File, size int64) (*fileMeta, int64, error) {
+	meta := &fileMeta{ContentType: "application/octet-stream"}
+	trailer := make([]byte, 4+len(fileMetaMagic))
+	if size < int64(len(trailer)) {
+		return meta, size, nil
+	}
+	if _, err := file.ReadAt(trailer, size-int64(len(trailer))); err != nil {
+		return nil, 0, err
+	}
+	if string(trailer[4:]) != fileMetaMagic {
+		return meta, size, nil
+	}
+	length := int64(binary.BigEndian.Uint32(trailer))
+	size -= int64(len(trailer))
+	if length > size {
+		return nil, 0, fmt.Errorf("awos: invalid metadata of %s", file.Name())
+	}
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, size-length); err != nil {
+		return nil, 0, err
+	}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, 0, fmt.Errorf("awos: invalid metadata of %s: %w", file.Name(), err)
+	}
+	return meta, size - length, nil
+}
+
+// get opens the object, nil if it does not exist and NotFoundAsNil is set
+func (f *File) get(ctx context.Context, key string) (*fileObject, error) {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return nil, err
+	}
+	var object *fileObject
+	err = f.do(ctx, "GetObject", bucketName, key, func(ctx context.Context) error {
+		object, err = openFileObject(f.Root, bucketName, key)
+		return err
+	})
+	if err != nil {
+		if f.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+// don't forget to call the close() method of the io.ReadCloser
+func (f *File) GetAsReader(key string, options ...GetOptions) (io.ReadCloser, error) {
+	return f.GetAsReaderWithContext(context.Background(), key, options...)
+}
+
+func (f *File) GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	object, err := f.get(ctx, key)
+	if err != nil || object == nil {
+		return nil, err
+	}
+	return f.decodeBody(object, options)
+}
+
+// don't forget to call the close() method of the io.ReadCloser
+func (f *File) GetWithMeta(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return f.GetWithMetaWithContext(context.Background(), key, attributes, options...)
+}
+
+func (f *File) GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	object, err := f.get(ctx, key)
+	if err != nil || object == nil {
+		return nil, nil, err
+	}
+	headers := object.headers(options)
+	body, err := f.decodeBody(object, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, getS3Meta(attributes, headers), nil
+}
+
+// GetWithMetaGZIP is GetWithMeta, the content is returned as stored
+func (f *File) GetWithMetaGZIP(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return f.GetWithMetaGZIPWithContext(context.Background(), key, attributes, options...)
+}
+
+func (f *File) GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return f.GetWithMetaWithContext(ctx, key, attributes, append(options, GetRaw())...)
+}
+
+func (f *File) Get(key string, options ...GetOptions) (string, error) {
+	return f.GetWithContext(context.Background(), key, options...)
+}
+
+func (f *File) GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := f.GetBytesWithContext(ctx, key, options...)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (f *File) GetBytes(key string, options ...GetOptions) ([]byte, error) {
+	return f.GetBytesWithContext(context.Background(), key, options...)
+}
+
+func (f *File) GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	object, err := f.get(ctx, key)
+	if err != nil || object == nil {
+		return nil, err
+	}
+	body, err := f.decodeBody(object, options)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// decodeBody decodes the object by its stored content encoding, see Config.decodeBody
+func (f *File) decodeBody(object *fileObject, options []GetOptions) (io.ReadCloser, error) {
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	var encoding string
+	if object.meta.ContentEncoding != nil {
+		encoding = *object.meta.ContentEncoding
+	}
+	return f.cfg.decodeBody(object, encoding, getOpts)
+}
+
+// Range returns length bytes from offset, the range is cut at the end of the object like s3
+func (f *File) Range(key string, offset int64, length int64) (io.ReadCloser, error) {
+	return f.RangeWithContext(context.Background(), key, offset, length)
+}
+
+func (f *File) RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return nil, err
+	}
+	var r io.ReadCloser
+	err = f.do(ctx, "GetObject", bucketName, key, func(ctx context.Context) error {
+		object, err := openFileObject(f.Root, bucketName, key)
+		if err != nil {
+			return err
+		}
+		if offset < 0 || length <= 0 || offset >= object.Size() {
+			object.Close()
+			return &Error{
+				StorageType: StorageTypeFile,
+				Op:          "GetObject",
+				Bucket:      bucketName,
+				Key:         key,
+				StatusCode:  http.StatusRequestedRangeNotSatisfiable,
+				Code:        "InvalidRange",
+				Err:         fmt.Errorf("range %d-%d is not satisfiable for size %d", offset, offset+length-1, object.Size()),
+			}
+		}
+		r = CombinedReadCloser{ReadCloser: object, Reader: io.NewSectionReader(object, offset, length)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Download copies the object to w
+func (f *File) Download(key string, w io.WriterAt, options ...DownloadOptions) error {
+	return f.DownloadWithContext(context.Background(), key, w, options...)
+}
+
+func (f *File) DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return err
+	}
+	return f.do(ctx, "GetObject", bucketName, key, func(ctx context.Context) error {
+		object, err := openFileObject(f.Root, bucketName, key)
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		buf := make([]byte, 32<<10)
+		var offset int64
+		for {
+			n, err := object.Read(buf)
+			if n > 0 {
+				if _, err := w.WriteAt(buf[:n], offset); err != nil {
+					return err
+				}
+				offset += int64(n)
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func (f *File) GetAndDecompress(key string) (string, error) {
+	return f.GetAndDecompressWithContext(context.Background(), key)
+}
+
+func (f *File) GetAndDecompressWithContext(ctx context.Context, key string) (string, error) {
+	body, err := f.GetAndDecompressAsReaderWithContext(ctx, key)
+	if err != nil || body == nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (f *File) GetAndDecompressAsReader(key string) (io.ReadCloser, error) {
+	return f.GetAndDecompressAsReaderWithContext(context.Background(), key)
+}
+
+func (f *File) GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := f.get(ctx, key)
+	if err != nil || object == nil {
+		return nil, err
+	}
+	compressor, encoding := compressionOf(object.headers(nil))
+	return decompressBody(object, compressor, encoding)
+}
+
+// Put writes the content and the metadata to a temporary file and renames it, so that the readers never see a partial
+// object or the metadata of another version.
+func (f *File) Put(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return f.PutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (f *File) PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return err
+	}
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+
+	var body io.Reader = reader
+	if reader == nil {
+		body = bytes.NewReader(nil)
+	}
+	if f.compressor != nil && reader != nil {
+		compressed, err := compressBody(f.compressor, reader, f.cfg.CompressLimit)
+		if err != nil {
+			return err
+		}
+		defer compressed.Close()
+		body = compressed
+		if compressed.compressed {
+			encoding := f.compressor.ContentEncoding()
+			putOptions.contentEncoding = &encoding
+			f.cfg.observeCompression(StorageTypeFile, bucketName, "PutObject", encoding, compressed.rawSize, compressed.size)
+		}
+	}
+	return f.do(ctx, "PutObject", bucketName, key, func(ctx context.Context) error {
+		return f.write(bucketName, key, body, meta, putOptions)
+	})
+}
+
+func (f *File) write(bucketName string, key string, reader io.Reader, meta map[string]string, putOptions *putOptions) error {
+	name, err := filePath(f.Root, bucketName, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(name), fileTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	err = writeFileObject(file, reader, meta, putOptions)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+// writeFileObject writes the content followed by the metadata, the json of fileMeta, its length and fileMetaMagic.
+// The metadata is after the content since the ETag is known once the content is written.
+func writeFileObject(w io.Writer, reader io.Reader, meta map[string]string, putOptions *putOptions) error {
+	h := md5.New()
+	if _, err := io.Copy(w, io.TeeReader(reader, h)); err != nil {
+		return err
+	}
+	object := &fileMeta{
+		Meta:               make(map[string]string, len(meta)),
+		ContentType:        putOptions.contentType,
+		ContentEncoding:    putOptions.contentEncoding,
+		ContentDisposition: putOptions.contentDisposition,
+		CacheControl:       putOptions.cacheControl,
+		Expires:            putOptions.expires,
+		ETag:               hex.EncodeToString(h.Sum(nil)),
+	}
+	// the metadata keys are kept as the s3 sdk returns them
+	for k, v := range meta {
+		object.Meta[http.CanonicalHeaderKey(k)] = v
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(data)))
+	_, err = w.Write(append(append(data, length...), fileMetaMagic...))
+	return err
+}
+
+func (f *File) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return f.CompressAndPutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (f *File) CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	body, size, rawSize, err := compressForPut(putOptions.compressType, reader)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+
+	if bucketName, err := f.getBucket(key); err == nil {
+		f.cfg.observeCompression(StorageTypeFile, bucketName, "PutObject", putOptions.compressType, rawSize, size)
+	}
+
+	meta["Compressor"] = putOptions.compressType
+
+	return f.PutWithContext(ctx, key, body, meta, options...)
+}
+
+// Upload streams the reader to the object, the body is never compressed
+func (f *File) Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return f.UploadWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (f *File) UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return err
+	}
+	putOptions := DefaultPutOptions()
+	for _, opt := range getUploadOptions(options).putOptions {
+		opt(putOptions)
+	}
+	return f.do(ctx, "PutObject", bucketName, key, func(ctx context.Context) error {
+		return f.write(bucketName, key, reader, meta, putOptions)
+	})
+}
+
+// Del removes the object and the directories left empty, deleting a missing object is not an error like s3
+func (f *File) Del(key string) error {
+	return f.DelWithContext(context.Background(), key)
+}
+
+func (f *File) DelWithContext(ctx context.Context, key string) error {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return err
+	}
+	return f.do(ctx, "DeleteObject", bucketName, key, func(ctx context.Context) error {
+		return f.remove(bucketName, key)
+	})
+}
+
+func (f *File) remove(bucketName string, key string) error {
+	name, err := filePath(f.Root, bucketName, key)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if stat.IsDir() {
+		return nil
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// os.Remove fails on the first directory which is not empty
+	bucketDir := filepath.Join(f.Root, bucketName)
+	for dir := filepath.Dir(name); dir != bucketDir && strings.HasPrefix(dir, bucketDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *File) DelMulti(keys []string) error {
+	return f.DelMultiWithContext(context.Background(), keys)
+}
+
+func (f *File) DelMultiWithContext(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := f.DelWithContext(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) Head(key string, attributes []string) (map[string]string, error) {
+	return f.HeadWithContext(context.Background(), key, attributes)
+}
+
+func (f *File) HeadWithContext(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return nil, err
+	}
+	var headers map[string]*string
+	err = f.do(ctx, "HeadObject", bucketName, key, func(ctx context.Context) error {
+		object, err := openFileObject(f.Root, bucketName, key)
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		headers = object.headers(nil)
+		return nil
+	})
+	if err != nil {
+		if f.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return getS3Meta(attributes, headers), nil
+}
+
+func (f *File) Exists(key string) (bool, error) {
+	return f.ExistsWithContext(context.Background(), key)
+}
+
+func (f *File) ExistsWithContext(ctx context.Context, key string) (bool, error) {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return false, err
+	}
+	err = f.do(ctx, "HeadObject", bucketName, key, func(ctx context.Context) error {
+		name, err := filePath(f.Root, bucketName, key)
+		if err != nil {
+			return err
+		}
+		stat, err := os.Stat(name)
+		if err == nil && stat.IsDir() {
+			return errIsDir(name)
+		}
+		return err
+	})
+	if err == nil {
+		return true, nil
+	}
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (f *File) ListObject(key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return f.ListObjectWithContext(context.Background(), key, prefix, marker, maxKeys, delimiter)
+}
+
+// ListObjectWithContext returns the keys after marker, the keys grouped into common prefixes by the delimiter are left out like s3
+func (f *File) ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	result, err := f.List(ctx, ListInput{Key: key, Prefix: prefix, Marker: marker, MaxKeys: maxKeys, Delimiter: delimiter})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(result.Objects))
+	for _, object := range result.Objects {
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
+}
+
+// List returns a page of objects in lexical key order, the NextContinuationToken is the last key or common prefix of the page
+func (f *File) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	if input.AllShards && len(f.ShardsBucket) > 0 {
+		buckets := make([]string, 0, len(f.ShardsBucket))
+		for _, bucketName := range f.ShardsBucket {
+			buckets = append(buckets, bucketName)
+		}
+		return listShards(ctx, buckets, input, f.list)
+	}
+
+	bucketName, err := f.getBucket(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	return f.list(ctx, bucketName, input)
+}
+
+func (f *File) list(ctx context.Context, bucketName string, input ListInput) (*ListResult, error) {
+	var res *ListResult
+	err := f.do(ctx, "ListObjectsV2", bucketName, "", func(ctx context.Context) error {
+		bucketDir := filepath.Join(f.Root, bucketName)
+		// only the directory of the prefix is walked
+		dir := bucketDir
+		if i := strings.LastIndex(input.Prefix, "/"); i > 0 {
+			dir = filepath.Join(bucketDir, filepath.FromSlash(input.Prefix[:i]))
+		}
+		modTimes := make(map[string]time.Time)
+		err := filepath.Walk(dir, func(name string, stat os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if stat.IsDir() || strings.HasPrefix(stat.Name(), fileTempPrefix) {
+				return nil
+			}
+			rel, err := filepath.Rel(bucketDir, name)
+			if err != nil {
+				return err
+			}
+			modTimes[filepath.ToSlash(rel)] = stat.ModTime()
+			return ctx.Err()
+		})
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(modTimes))
+		for key := range modTimes {
+			keys = append(keys, key)
+		}
+		res, err = pageKeys(keys, input, func(key string) (ObjectInfo, error) {
+			object, err := openFileObject(f.Root, bucketName, key)
+			if err != nil {
+				return ObjectInfo{}, err
+			}
+			defer object.Close()
+			return ObjectInfo{
+				Key:          key,
+				Size:         object.Size(),
+				ETag:         object.meta.ETag,
+				LastModified: object.modTime,
+				StorageClass: "STANDARD",
+			}, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SignURL returns the url of the object under FileURL, signed by HMAC-SHA256 of AccessKeySecret, see NewFileHandler
+func (f *File) SignURL(key string, expired int64, options ...SignOptions) (string, error) {
+	bucketName, err := f.getBucket(key)
+	if err != nil {
+		return "", err
+	}
+	signOptions := DefaultSignOptions()
+	for _, opt := range options {
+		opt(signOptions)
+	}
+	if signOptions.process != nil {
+		return "", errors.New("awos: process option is not supported for file")
+	}
+	if f.cfg.FileURL == "" || f.cfg.AccessKeySecret == "" {
+		return "", errors.New("awos: FileURL and AccessKeySecret are required by SignURL of file")
+	}
+	if err := checkFileKey(key); err != nil {
+		return "", err
+	}
+	return signFileURL(f.cfg.FileURL, f.cfg.AccessKeySecret, bucketName, key, time.Now().Add(time.Duration(expired)*time.Second)), nil
+}
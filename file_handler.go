@@ -0,0 +1,98 @@
+package awos
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signFileURL returns the url of the object under base, valid until expires
+func signFileURL(base string, secret string, bucket string, key string, expires time.Time) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	query := url.Values{}
+	query.Set("Expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("Signature", fileSignature(secret, bucket, key, query.Get("Expires")))
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(segments, "/") + "?" + query.Encode()
+}
+
+func fileSignature(secret string, bucket string, key string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(http.MethodGet + "\n/" + bucket + "/" + key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type fileHandler struct {
+	root   string
+	secret string
+}
+
+// NewFileHandler serves the urls returned by SignURL of the file storage, root and secret are the Endpoint and
+// the AccessKeySecret of the client. The path of the request is /bucket/key, so mount it at FileURL with http.StripPrefix:
+//
+//	http.Handle("/awos/", http.StripPrefix("/awos", awos.NewFileHandler("/var/lib/awos", secret)))
+//
+// Range, If-None-Match and HEAD requests are supported, the url is rejected with 403 once it expires.
+func NewFileHandler(root string, secret string) http.Handler {
+	return &fileHandler{root: root, secret: secret}
+}
+
+func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || checkFileKey(parts[0]) != nil || strings.Contains(parts[0], "/") {
+		http.NotFound(w, r)
+		return
+	}
+	bucket, key := parts[0], parts[1]
+
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires || h.secret == "" ||
+		!hmac.Equal([]byte(query.Get("Signature")), []byte(fileSignature(h.secret, bucket, key, query.Get("Expires")))) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	object, err := openFileObject(h.root, bucket, key)
+	if err != nil {
+		if IsNotFound(wrapFileError("GetObject", bucket, key, err)) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer object.Close()
+
+	meta := object.meta
+	header := w.Header()
+	header.Set("Content-Type", meta.ContentType)
+	if meta.ContentEncoding != nil {
+		header.Set("Content-Encoding", *meta.ContentEncoding)
+	}
+	if meta.ContentDisposition != nil {
+		header.Set("Content-Disposition", *meta.ContentDisposition)
+	}
+	if meta.CacheControl != nil {
+		header.Set("Cache-Control", *meta.CacheControl)
+	}
+	if meta.Expires != nil {
+		header.Set("Expires", meta.Expires.UTC().Format(http.TimeFormat))
+	}
+	if meta.ETag != "" {
+		header.Set("ETag", `"`+meta.ETag+`"`)
+	}
+	http.ServeContent(w, r, "", object.modTime, object)
+}
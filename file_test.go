@@ -0,0 +1,326 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFileClient(t *testing.T, options *Options) Client {
+	options.StorageType = StorageTypeFile
+	options.Endpoint = t.TempDir()
+	if options.Bucket == "" {
+		options.Bucket = "test"
+	}
+	client, err := New(options)
+	assert.NoError(t, err)
+	return client
+}
+
+func TestFile_Put(t *testing.T) {
+	client := newFileClient(t, &Options{})
+	err := client.Put("dir/key", strings.NewReader("content"), map[string]string{"test-key": "value"},
+		PutWithContentType("application/json"), PutWithContentEncoding("identity"))
+	assert.NoError(t, err)
+
+	res, err := client.Get("dir/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "content", res)
+
+	meta, err := client.Head("dir/key", []string{"test-key", "Content-Type", "Content-Encoding", "Content-Length"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"test-key":         "value",
+		"Content-Type":     "application/json",
+		"Content-Encoding": "identity",
+		"Content-Length":   "7",
+	}, meta)
+
+	// only the object is left, with its metadata
+	root := client.(*File).Root
+	entries, err := ioutil.ReadDir(filepath.Join(root, "test", "dir"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = client.Get("dir")
+	assert.True(t, IsNotFound(err))
+	_, err = client.Get("missing")
+	assert.True(t, IsNotFound(err))
+	ok, err := client.Exists("dir/key")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	err = client.Put("../escape", strings.NewReader("content"), nil)
+	assert.Error(t, err)
+	// the content can't replace the directory, and the temporary file is not left behind
+	err = client.Put("dir", strings.NewReader("content"), map[string]string{"test-key": "value"})
+	assert.Error(t, err)
+	entries, err = ioutil.ReadDir(filepath.Join(root, "test"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// a file not written by Put is an object without metadata
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "test", "dir", "plain"), []byte("plain"), 0644))
+	reader, meta, err := client.GetWithMeta("dir/plain", []string{"Content-Type", "Content-Length"})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, "plain", string(data))
+	assert.Equal(t, map[string]string{"Content-Type": "application/octet-stream", "Content-Length": "5"}, meta)
+	assert.NoError(t, client.Del("dir/plain"))
+
+	assert.NoError(t, client.Del("dir/key"))
+	assert.NoError(t, client.Del("dir/key"))
+	_, err = os.Stat(filepath.Join(root, "test", "dir"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFile_ConcurrentPut(t *testing.T) {
+	client := newFileClient(t, &Options{EnableDecompressor: true})
+	plain := strings.Repeat("plain", 100)
+	compressed := strings.Repeat("gzip", 100)
+	gz, _, err := DefaultGzipCompressor.Compress(strings.NewReader(compressed))
+	assert.NoError(t, err)
+	gzipped, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Put("key", strings.NewReader(plain), map[string]string{"version": "plain"}))
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				var err error
+				if (i+j)%2 == 0 {
+					err = client.Put("key", bytes.NewReader(gzipped), map[string]string{"version": "gzip"}, PutWithContentEncoding("gzip"))
+				} else {
+					err = client.Put("key", strings.NewReader(plain), map[string]string{"version": "plain"})
+				}
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// every read gets the content and the metadata of the same version
+	for reads := 0; ; reads++ {
+		select {
+		case <-done:
+			assert.Greater(t, reads, 0)
+			return
+		default:
+		}
+		reader, meta, err := client.GetWithMeta("key", []string{"version"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Close())
+		if meta["version"] == "gzip" {
+			assert.Equal(t, compressed, string(data))
+		} else {
+			assert.Equal(t, plain, string(data))
+		}
+	}
+}
+
+func TestFile_Range(t *testing.T) {
+	client := newFileClient(t, &Options{})
+	assert.NoError(t, client.Put("key", strings.NewReader("0123456789"), nil))
+
+	r, err := client.Range("key", 2, 3)
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(r)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "234", string(data))
+
+	r, err = client.Range("key", 8, 5)
+	assert.NoError(t, err)
+	data, _ = ioutil.ReadAll(r)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "89", string(data))
+
+	_, err = client.Range("key", 10, 1)
+	assert.Error(t, err)
+}
+
+func TestFile_List(t *testing.T) {
+	client := newFileClient(t, &Options{})
+	for _, key := range []string{"a-b", "a/1", "a/2", "b", "c/1", "c/2/3"} {
+		assert.NoError(t, client.Put(key, strings.NewReader(key), nil))
+	}
+
+	keys, err := client.ListObject("", "", "", 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a-b", "a/1", "a/2", "b", "c/1", "c/2/3"}, keys)
+
+	keys, err = client.ListObject("", "c/", "", 0, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c/1"}, keys)
+
+	var objects, prefixes []string
+	err = Walk(context.Background(), client, ListInput{MaxKeys: 1, Delimiter: "/"}, func(object ObjectInfo) error {
+		objects = append(objects, object.Key)
+		assert.Equal(t, int64(len(object.Key)), object.Size)
+		assert.Len(t, object.ETag, 32)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a-b", "b"}, objects)
+
+	input := ListInput{MaxKeys: 2, Delimiter: "/"}
+	for {
+		result, err := client.List(context.Background(), input)
+		assert.NoError(t, err)
+		prefixes = append(prefixes, result.CommonPrefixes...)
+		if !result.IsTruncated {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"a/", "c/"}, prefixes)
+}
+
+func TestFile_CompressAndPut(t *testing.T) {
+	client := newFileClient(t, &Options{EnableCompressor: true, CompressType: "gzip", CompressLimit: 100})
+	content := strings.Repeat("content", 100)
+	assert.NoError(t, client.CompressAndPut("snappy", strings.NewReader(content), nil))
+	res, err := client.GetAndDecompress("snappy")
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+
+	assert.NoError(t, client.Put("gzip", strings.NewReader(content), nil))
+	meta, err := client.Head("gzip", []string{"Content-Encoding"})
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", meta["Content-Encoding"])
+}
+
+func TestFile_Decompressor(t *testing.T) {
+	client := newFileClient(t, &Options{EnableCompressor: true, CompressType: "gzip", CompressLimit: 100, EnableDecompressor: true})
+	content := strings.Repeat("content", 100)
+	assert.NoError(t, client.Put("gzip", strings.NewReader(content), nil))
+	assert.NoError(t, client.Put("empty", strings.NewReader(""), nil, PutWithContentEncoding("gzip")))
+
+	res, err := client.Get("gzip")
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+	reader, meta, err := client.GetWithMeta("gzip", []string{"Content-Encoding"})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "gzip", meta["Content-Encoding"])
+	res, err = client.Get("empty")
+	assert.NoError(t, err)
+	assert.Equal(t, "", res)
+
+	// the content as stored
+	raw, err := client.GetBytes("gzip", GetRaw())
+	assert.NoError(t, err)
+	assert.NotEqual(t, content, string(raw))
+	gz, err := DefaultGzipCompressor.NewReader(bytes.NewReader(raw))
+	assert.NoError(t, err)
+	decoded, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(decoded))
+	reader, _, err = client.GetWithMetaGZIP("gzip", nil)
+	assert.NoError(t, err)
+	data, err = ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Close())
+	assert.Equal(t, raw, data)
+
+	// a corrupted content is an error
+	assert.NoError(t, client.Put("corrupted", strings.NewReader("content"), nil, PutWithContentEncoding("gzip")))
+	_, err = client.Get("corrupted")
+	assert.Error(t, err)
+}
+
+func TestFile_CompressType(t *testing.T) {
+	content := strings.Repeat("content", 100)
+	for _, compressType := range []string{"snappy", "zstd", "lz4", "br"} {
+		client := newFileClient(t, &Options{EnableCompressor: true, CompressType: compressType, CompressLimit: 100, EnableDecompressor: true})
+		assert.NoError(t, client.Put("key", strings.NewReader(content), nil))
+		meta, err := client.Head("key", []string{"Content-Encoding"})
+		assert.NoError(t, err)
+		assert.Equal(t, compressType, meta["Content-Encoding"])
+		res, err := client.Get("key")
+		assert.NoError(t, err)
+		assert.Equal(t, content, res)
+	}
+}
+
+func TestFile_NotFoundAsNil(t *testing.T) {
+	client := newFileClient(t, &Options{NotFoundAsNil: true})
+	res, err := client.GetBytes("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+	meta, err := client.Head("missing", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, meta)
+}
+
+func TestFile_SignURL(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := newFileClient(t, &Options{AccessKeySecret: "secret", FileURL: server.URL + "/awos"})
+	mux.Handle("/awos/", http.StripPrefix("/awos", NewFileHandler(client.(*File).Root, "secret")))
+
+	assert.NoError(t, client.Put("dir/a key", strings.NewReader("content"), nil, PutWithContentType("text/html")))
+	signed, err := client.SignURL("dir/a key", 60)
+	assert.NoError(t, err)
+
+	res, err := http.Get(signed)
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, "text/html", res.Header.Get("Content-Type"))
+
+	req, _ := http.NewRequest(http.MethodGet, signed, nil)
+	req.Header.Set("Range", "bytes=1-3")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	data, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+	assert.Equal(t, "ont", string(data))
+
+	res, err = http.Get(strings.Replace(signed, "a%20key", "b%20key", 1))
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	expired := signFileURL(server.URL+"/awos", "secret", "test", "dir/a key", time.Now().Add(-time.Second))
+	res, err = http.Get(expired)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestFile_Validate(t *testing.T) {
+	cfg := &Config{BucketConfig: BucketConfig{StorageType: "file", Endpoint: t.TempDir(), Bucket: "test"}}
+	assert.NoError(t, cfg.Validate())
+	cfg.Endpoint = ""
+	assert.EqualError(t, cfg.Validate(), "awos: invalid config: Endpoint, the root directory, is required for file")
+}
@@ -0,0 +1,54 @@
+package awos
+
+import (
+	"context"
+)
+
+// requestInfo describes a request sent to oss/s3, it's kept in the context of the request
+// so that the interceptors and the transport can label what they record
+type requestInfo struct {
+	StorageType string
+	// Op the operation of the sdk, e.g. GetObject
+	Op     string
+	Bucket string
+	// Shard the shard of the bucket, empty without shards
+	Shard string
+	Key   string
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// interceptor wraps every attempt of a request to oss/s3, next sends the request and returns *Error on failure
+type interceptor func(ctx context.Context, info *requestInfo, next func(ctx context.Context) error) error
+
+// getInterceptors returns the interceptors enabled by the config, the first one is the outermost
+func getInterceptors(cfg *Config) []interceptor {
+	res := make([]interceptor, 0)
+	if cfg.EnableTraceInterceptor {
+		res = append(res, traceInterceptor(cfg))
+	}
+	if cfg.EnableMetricInterceptor {
+		res = append(res, metricInterceptor)
+	}
+	return res
+}
+
+// runInterceptors sends a single attempt of the request through the interceptors, wrap converts the error of fn to *Error
+func runInterceptors(ctx context.Context, interceptors []interceptor, info *requestInfo, wrap func(err error) error, fn func(ctx context.Context) error) error {
+	ctx = context.WithValue(ctx, requestInfoKey{}, info)
+	next := func(ctx context.Context) error {
+		return wrap(fn(ctx))
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		current, inner := interceptors[i], next
+		next = func(ctx context.Context) error {
+			return current(ctx, info, inner)
+		}
+	}
+	return next(ctx)
+}
@@ -0,0 +1,128 @@
+package awos
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListInput for List method
+type ListInput struct {
+	// Optional, only used to choose the shard bucket
+	Key string
+	// Optional, only list the objects whose key begins with the prefix
+	Prefix string
+	// Optional, list the objects after this key, only used for the first page
+	Marker string
+	// Optional, the NextContinuationToken of the previous page
+	ContinuationToken string
+	// Optional, max number of objects and common prefixes of a page, 0 means the storage default (1000)
+	MaxKeys int
+	// Optional, group the keys containing the delimiter after the prefix into CommonPrefixes
+	Delimiter string
+	// Optional, list every shard bucket instead of the one chosen by Key and merge them in key order,
+	// the NextContinuationToken of a merged page can only be used with AllShards
+	AllShards bool
+}
+
+// ObjectInfo is an object returned by List
+type ObjectInfo struct {
+	Key  string
+	Size int64
+	// ETag without the surrounding quotes
+	ETag         string
+	LastModified time.Time
+	StorageClass string
+}
+
+// ListResult is a page returned by List
+type ListResult struct {
+	Objects        []ObjectInfo
+	CommonPrefixes []string
+	// IsTruncated reports whether there are more pages, pass NextContinuationToken to get the next one
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// Lister is implemented by every Client
+type Lister interface {
+	List(ctx context.Context, input ListInput) (*ListResult, error)
+}
+
+// WalkFunc is called for every listed object, a non-nil error stops the walk and is returned by Walk
+type WalkFunc func(object ObjectInfo) error
+
+// Walk lists the objects page by page and calls fn for each of them in key order
+func Walk(ctx context.Context, lister Lister, input ListInput, fn WalkFunc) error {
+	for {
+		result, err := lister.List(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, object := range result.Objects {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		input.Marker = ""
+		input.ContinuationToken = result.NextContinuationToken
+	}
+}
+
+// pageKeys returns the page of input of the keys like ListObjectsV2, for the storages listing the keys by themselves.
+// The NextContinuationToken is the last key or common prefix of the page, object returns the ObjectInfo of a listed key.
+func pageKeys(keys []string, input ListInput, object func(key string) (ObjectInfo, error)) (*ListResult, error) {
+	after := input.Marker
+	if input.ContinuationToken != "" {
+		after = input.ContinuationToken
+	}
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	// the keys of the common prefix ending the previous page are skipped
+	afterPrefix := input.Delimiter != "" && len(after) > len(input.Prefix) && strings.HasSuffix(after, input.Delimiter)
+
+	matched := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, input.Prefix) && key > after && !(afterPrefix && strings.HasPrefix(key, after)) {
+			matched = append(matched, key)
+		}
+	}
+	sort.Strings(matched)
+
+	res := &ListResult{Objects: make([]ObjectInfo, 0), CommonPrefixes: make([]string, 0)}
+	last := ""
+	for _, key := range matched {
+		commonPrefix := ""
+		if input.Delimiter != "" {
+			if i := strings.Index(key[len(input.Prefix):], input.Delimiter); i >= 0 {
+				commonPrefix = key[:len(input.Prefix)+i+len(input.Delimiter)]
+			}
+		}
+		if commonPrefix != "" && commonPrefix == last {
+			continue
+		}
+		if len(res.Objects)+len(res.CommonPrefixes) == maxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = last
+			break
+		}
+		if commonPrefix != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix)
+			last = commonPrefix
+			continue
+		}
+		info, err := object(key)
+		if err != nil {
+			return nil, err
+		}
+		res.Objects = append(res.Objects, info)
+		last = key
+	}
+	return res, nil
+}
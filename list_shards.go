@@ -0,0 +1,221 @@
+package awos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultMaxKeys is the page size of oss and s3 when MaxKeys is not set
+const defaultMaxKeys = 1000
+
+// shardsToken is the combined continuation token of all shard buckets
+type shardsToken struct {
+	// Markers the last consumed key of each bucket
+	Markers map[string]string `json:"m,omitempty"`
+	// Done the buckets which have been listed completely
+	Done []string `json:"d,omitempty"`
+}
+
+func decodeShardsToken(token string) (*shardsToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shards continuation token: %w", err)
+	}
+	res := &shardsToken{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("invalid shards continuation token: %w", err)
+	}
+	return res, nil
+}
+
+func (t *shardsToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+type shardsEntry struct {
+	key      string
+	bucket   string
+	isPrefix bool
+	object   ObjectInfo
+}
+
+// listShards lists every bucket concurrently and merges the results in key order.
+// Every bucket is listed after its own marker, so a merged page never skips a key of a bucket
+// whose page was cut by MaxKeys.
+func listShards(ctx context.Context, buckets []string, input ListInput,
+	list func(ctx context.Context, bucket string, input ListInput) (*ListResult, error)) (*ListResult, error) {
+	sort.Strings(buckets)
+	buckets = dedupSorted(buckets)
+
+	token := &shardsToken{Markers: make(map[string]string)}
+	if input.ContinuationToken != "" {
+		var err error
+		token, err = decodeShardsToken(input.ContinuationToken)
+		if err != nil {
+			return nil, err
+		}
+		if token.Markers == nil {
+			token.Markers = make(map[string]string)
+		}
+	} else if input.Marker != "" {
+		for _, bucket := range buckets {
+			token.Markers[bucket] = input.Marker
+		}
+	}
+	done := make(map[string]bool)
+	for _, bucket := range token.Done {
+		done[bucket] = true
+	}
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultMaxKeys
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		results  = make(map[string]*ListResult)
+	)
+	for _, bucket := range buckets {
+		if done[bucket] {
+			continue
+		}
+		wg.Add(1)
+		go func(bucket string) {
+			defer wg.Done()
+			result, err := list(ctx, bucket, ListInput{
+				Prefix:    input.Prefix,
+				Marker:    token.Markers[bucket],
+				MaxKeys:   maxKeys,
+				Delimiter: input.Delimiter,
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("list bucket %s failed: %w", bucket, err)
+				}
+				return
+			}
+			results[bucket] = result
+		}(bucket)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	entries := make([]shardsEntry, 0)
+	for bucket, result := range results {
+		for _, object := range result.Objects {
+			entries = append(entries, shardsEntry{key: object.Key, bucket: bucket, object: object})
+		}
+		for _, prefix := range result.CommonPrefixes {
+			entries = append(entries, shardsEntry{key: prefix, bucket: bucket, isPrefix: true})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].bucket < entries[j].bucket
+	})
+
+	// a bucket whose page was truncated may have unseen keys after its last returned one
+	bound, bounded := "", false
+	for _, result := range results {
+		if !result.IsTruncated {
+			continue
+		}
+		if last := lastListedKey(result); !bounded || last < bound {
+			bound, bounded = last, true
+		}
+	}
+
+	res := &ListResult{
+		Objects:        make([]ObjectInfo, 0),
+		CommonPrefixes: make([]string, 0),
+	}
+	consumed := make(map[string]int)
+	emitted := 0
+	for _, entry := range entries {
+		// the same common prefix may come from several buckets, as well as an object moved but not yet deleted,
+		// it is returned only once, from the first bucket
+		if entry.isPrefix {
+			if n := len(res.CommonPrefixes); n > 0 && res.CommonPrefixes[n-1] == entry.key {
+				consumed[entry.bucket]++
+				token.Markers[entry.bucket] = prefixMarker(entry.key)
+				continue
+			}
+		} else if n := len(res.Objects); n > 0 && res.Objects[n-1].Key == entry.key {
+			consumed[entry.bucket]++
+			token.Markers[entry.bucket] = entry.key
+			continue
+		}
+		if emitted == maxKeys || (bounded && entry.key > bound) {
+			break
+		}
+		emitted++
+		consumed[entry.bucket]++
+		if entry.isPrefix {
+			res.CommonPrefixes = append(res.CommonPrefixes, entry.key)
+			token.Markers[entry.bucket] = prefixMarker(entry.key)
+		} else {
+			res.Objects = append(res.Objects, entry.object)
+			token.Markers[entry.bucket] = entry.key
+		}
+	}
+
+	for bucket, result := range results {
+		if !result.IsTruncated && consumed[bucket] == len(result.Objects)+len(result.CommonPrefixes) {
+			done[bucket] = true
+		}
+	}
+	token.Done = token.Done[:0]
+	for _, bucket := range buckets {
+		if done[bucket] {
+			token.Done = append(token.Done, bucket)
+			delete(token.Markers, bucket)
+		} else {
+			res.IsTruncated = true
+		}
+	}
+	if res.IsTruncated {
+		res.NextContinuationToken = token.encode()
+	}
+	return res, nil
+}
+
+func lastListedKey(result *ListResult) string {
+	last := ""
+	if n := len(result.Objects); n > 0 {
+		last = result.Objects[n-1].Key
+	}
+	if n := len(result.CommonPrefixes); n > 0 && result.CommonPrefixes[n-1] > last {
+		last = result.CommonPrefixes[n-1]
+	}
+	return last
+}
+
+// prefixMarker returns a marker after every key beginning with the common prefix,
+// so that the next page does not roll them up into the same prefix again
+func prefixMarker(prefix string) string {
+	return prefix + string(utf8.MaxRune)
+}
+
+func dedupSorted(values []string) []string {
+	res := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package awos
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listSorted lists keys like ListObjectsV2 does
+func listSorted(keys []string, input ListInput) *ListResult {
+	sort.Strings(keys)
+	res := &ListResult{Objects: make([]ObjectInfo, 0), CommonPrefixes: make([]string, 0)}
+	count := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, input.Prefix) || key <= input.Marker {
+			continue
+		}
+		if input.Delimiter != "" {
+			if i := strings.Index(key[len(input.Prefix):], input.Delimiter); i >= 0 {
+				prefix := key[:len(input.Prefix)+i+len(input.Delimiter)]
+				if n := len(res.CommonPrefixes); n > 0 && res.CommonPrefixes[n-1] == prefix {
+					continue
+				}
+				if prefix <= input.Marker {
+					continue
+				}
+				if count == input.MaxKeys {
+					res.IsTruncated = true
+					break
+				}
+				count++
+				res.CommonPrefixes = append(res.CommonPrefixes, prefix)
+				continue
+			}
+		}
+		if count == input.MaxKeys {
+			res.IsTruncated = true
+			break
+		}
+		count++
+		res.Objects = append(res.Objects, ObjectInfo{Key: key})
+	}
+	return res
+}
+
+func TestListShards(t *testing.T) {
+	shards := map[string][]string{
+		"content-abc": {"1a", "2b", "3c", "dir/a", "x/1a"},
+		"content-def": {"1d", "2e", "3f", "dir/d", "x/1f"},
+	}
+	list := func(ctx context.Context, bucket string, input ListInput) (*ListResult, error) {
+		return listSorted(shards[bucket], input), nil
+	}
+
+	keys := make([]string, 0)
+	input := ListInput{MaxKeys: 3, AllShards: true}
+	pages := 0
+	for {
+		result, err := listShards(context.Background(), []string{"content-def", "content-abc", "content-abc"}, input, list)
+		assert.NoError(t, err)
+		pages++
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"1a", "1d", "2b", "2e", "3c", "3f", "dir/a", "dir/d", "x/1a", "x/1f"}, keys)
+	assert.Equal(t, 4, pages)
+
+	prefixes := make([]string, 0)
+	keys = keys[:0]
+	input = ListInput{MaxKeys: 2, Delimiter: "/", Marker: "1d", AllShards: true}
+	for {
+		result, err := listShards(context.Background(), []string{"content-abc", "content-def"}, input, list)
+		assert.NoError(t, err)
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		prefixes = append(prefixes, result.CommonPrefixes...)
+		if !result.IsTruncated {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"2b", "2e", "3c", "3f"}, keys)
+	assert.Equal(t, []string{"dir/", "x/"}, prefixes)
+}
@@ -0,0 +1,54 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pagedLister struct {
+	pages  []*ListResult
+	inputs []ListInput
+}
+
+func (l *pagedLister) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	l.inputs = append(l.inputs, input)
+	page := 0
+	if input.ContinuationToken != "" {
+		page, _ = strconv.Atoi(input.ContinuationToken)
+	}
+	return l.pages[page], nil
+}
+
+func TestWalk(t *testing.T) {
+	lister := &pagedLister{pages: []*ListResult{
+		{Objects: []ObjectInfo{{Key: "a"}, {Key: "b"}}, IsTruncated: true, NextContinuationToken: "1"},
+		{Objects: []ObjectInfo{{Key: "c"}}, IsTruncated: true, NextContinuationToken: "2"},
+		{Objects: []ObjectInfo{{Key: "d"}}},
+	}}
+
+	keys := make([]string, 0)
+	err := Walk(context.Background(), lister, ListInput{Prefix: "p", Marker: "0"}, func(object ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, keys)
+	assert.Equal(t, []ListInput{
+		{Prefix: "p", Marker: "0"},
+		{Prefix: "p", ContinuationToken: "1"},
+		{Prefix: "p", ContinuationToken: "2"},
+	}, lister.inputs)
+
+	stop := errors.New("stop")
+	err = Walk(context.Background(), lister, ListInput{}, func(object ObjectInfo) error {
+		if object.Key == "c" {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+}
@@ -0,0 +1,185 @@
+package awos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile loads and validates the config of a json, yaml or toml file, chosen by the extension of path.
+// The fields absent from the file keep the values of DefaultConfig.
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("awos: read config: %w", err)
+	}
+	cfg := DefaultConfig()
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown field %q", meta.Undecoded()[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("awos: unsupported config file %q, only supports .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("awos: parse config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFromEnv loads and validates the config of the environment variables named prefix + the field of BucketConfig,
+// e.g. with prefix "AWOS_": AWOS_StorageType, AWOS_AccessKeyID, AWOS_Shards=abc,def, AWOS_EnableCompressor=true.
+// prefix + "Buckets" is the json of Config.Buckets. The unset variables keep the values of DefaultConfig.
+func LoadFromEnv(prefix string) (*Config, error) {
+	cfg := DefaultConfig()
+	if err := loadEnv(prefix, reflect.ValueOf(&cfg.BucketConfig).Elem()); err != nil {
+		return nil, err
+	}
+	if value, ok := os.LookupEnv(prefix + "Debug"); ok {
+		debug, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("awos: invalid %sDebug %q: %w", prefix, value, err)
+		}
+		cfg.Debug = debug
+	}
+	if value, ok := os.LookupEnv(prefix + "Buckets"); ok && value != "" {
+		if err := json.Unmarshal([]byte(value), &cfg.Buckets); err != nil {
+			return nil, fmt.Errorf("awos: invalid %sBuckets: %w", prefix, err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadEnv sets the fields of the struct v from the environment variables
+func loadEnv(prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := prefix + t.Field(i).Name
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("awos: invalid %s %q: %w", name, value, err)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("awos: invalid %s %q: %w", name, value, err)
+			}
+			field.SetInt(n)
+		case reflect.Slice:
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		}
+	}
+	return nil
+}
+
+// Validate checks the required fields of the config, or of every bucket of Buckets if any
+func (c *Config) Validate() error {
+	var problems []string
+	if len(c.Buckets) == 0 {
+		problems = c.BucketConfig.validate("")
+	} else {
+		names := make([]string, 0, len(c.Buckets))
+		for name := range c.Buckets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b, err := c.bucket(name)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("bucket %q: Bucket is required", name))
+				continue
+			}
+			problems = append(problems, b.BucketConfig.validate(fmt.Sprintf("bucket %q: ", name))...)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("awos: invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validate returns the problems of the fields, each starts with prefix
+func (b *BucketConfig) validate(prefix string) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, prefix+fmt.Sprintf(format, args...))
+	}
+	storageType := strings.ToLower(b.StorageType)
+	if storageType != StorageTypeOSS && storageType != StorageTypeS3 && storageType != StorageTypeFile {
+		add("StorageType %q is unknown, only supports oss, s3 or file", b.StorageType)
+	}
+	// the file storage has no credentials, the secret only signs the urls
+	if b.AccessKeyID == "" && storageType != StorageTypeFile {
+		add("AccessKeyID is required")
+	}
+	if b.AccessKeySecret == "" && storageType != StorageTypeFile {
+		add("AccessKeySecret is required")
+	}
+	if b.Bucket == "" {
+		add("Bucket is required")
+	}
+	if storageType == StorageTypeOSS && b.Endpoint == "" {
+		add("Endpoint is required for oss")
+	}
+	if storageType == StorageTypeFile && b.Endpoint == "" {
+		add("Endpoint, the root directory, is required for file")
+	}
+	if storageType == StorageTypeS3 && b.Region == "" {
+		add("Region is required for s3")
+	}
+	for _, shard := range b.Shards {
+		if shard == "" {
+			add("Shards must not contain an empty shard")
+			break
+		}
+	}
+	if b.EnableCompressor {
+		if _, err := getCompressor(b.CompressType); err != nil {
+			add("CompressType %q is unknown", b.CompressType)
+		}
+	}
+	return problems
+}
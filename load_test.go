@@ -0,0 +1,169 @@
+package awos
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"awos.json": `{
+  "storageType": "s3",
+  "accessKeyID": "ak",
+  "accessKeySecret": "sk",
+  "endpoint": "http://minio:9000",
+  "region": "cn-north-1",
+  "s3ForcePathStyle": true,
+  "s3HttpTransportMaxConnsPerHost": 100,
+  "enableMetricInterceptor": false,
+  "buckets": {
+    "fileContent": {"bucket": "file-content", "shards": ["abc", "def"], "enableCompressor": true, "compressType": "gzip", "compressLimit": 1024},
+    "fileSnapshots": {"bucket": "file-snapshots", "enableTraceInterceptor": false}
+  }
+}`,
+		"awos.yaml": `
+storageType: s3
+accessKeyID: ak
+accessKeySecret: sk
+endpoint: http://minio:9000
+region: cn-north-1
+s3ForcePathStyle: true
+s3HttpTransportMaxConnsPerHost: 100
+enableMetricInterceptor: false
+buckets:
+  fileContent:
+    bucket: file-content
+    shards: [abc, def]
+    enableCompressor: true
+    compressType: gzip
+    compressLimit: 1024
+  fileSnapshots:
+    bucket: file-snapshots
+    enableTraceInterceptor: false
+`,
+		"awos.toml": `
+storageType = "s3"
+accessKeyID = "ak"
+accessKeySecret = "sk"
+endpoint = "http://minio:9000"
+region = "cn-north-1"
+s3ForcePathStyle = true
+s3HttpTransportMaxConnsPerHost = 100
+enableMetricInterceptor = false
+
+[buckets.fileContent]
+bucket = "file-content"
+shards = ["abc", "def"]
+enableCompressor = true
+compressType = "gzip"
+compressLimit = 1024
+
+[buckets.fileSnapshots]
+bucket = "file-snapshots"
+enableTraceInterceptor = false
+`,
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+			cfg, err := LoadConfigFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "s3", cfg.StorageType)
+			assert.Equal(t, "ak", cfg.AccessKeyID)
+			assert.True(t, cfg.S3ForcePathStyle)
+			assert.Equal(t, 100, cfg.S3HttpTransportMaxConnsPerHost)
+			assert.Equal(t, int64(60), cfg.S3HttpTimeoutSecs)
+			assert.True(t, cfg.EnableTraceInterceptor)
+			assert.False(t, cfg.EnableMetricInterceptor)
+			assert.Equal(t, BucketOverride{Bucket: "file-content", Shards: []string{"abc", "def"}, EnableCompressor: Bool(true), CompressType: "gzip", CompressLimit: 1024}, cfg.Buckets["fileContent"])
+			snapshots, err := cfg.bucket("fileSnapshots")
+			assert.NoError(t, err)
+			assert.False(t, snapshots.EnableTraceInterceptor)
+			content, err := cfg.bucket("fileContent")
+			assert.NoError(t, err)
+			assert.True(t, content.EnableTraceInterceptor)
+		})
+	}
+
+	path := filepath.Join(dir, "unknown.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"storageTyp": "s3"}`), 0644))
+	_, err := LoadConfigFile(path)
+	assert.ErrorContains(t, err, "storageTyp")
+	_, err = LoadConfigFile(filepath.Join(dir, "awos.ini"))
+	assert.Error(t, err)
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("AWOS_StorageType", "OSS")
+	t.Setenv("AWOS_AccessKeyID", "ak")
+	t.Setenv("AWOS_AccessKeySecret", "sk")
+	t.Setenv("AWOS_Endpoint", "oss-cn-beijing.aliyuncs.com")
+	t.Setenv("AWOS_Bucket", "content")
+	t.Setenv("AWOS_Shards", "abc, def")
+	t.Setenv("AWOS_EnableCompressor", "true")
+	t.Setenv("AWOS_CompressType", "gzip")
+	t.Setenv("AWOS_CompressLimit", "1024")
+	cfg, err := LoadFromEnv("AWOS_")
+	assert.NoError(t, err)
+	assert.Equal(t, "OSS", cfg.StorageType)
+	assert.Equal(t, []string{"abc", "def"}, cfg.Shards)
+	assert.True(t, cfg.EnableCompressor)
+	assert.Equal(t, 1024, cfg.CompressLimit)
+	options := cfg.Options()
+	assert.Equal(t, "content", options.Bucket)
+	assert.Equal(t, 1024, options.CompressLimit)
+
+	t.Setenv("AWOS_CompressLimit", "1k")
+	_, err = LoadFromEnv("AWOS_")
+	assert.ErrorContains(t, err, "AWOS_CompressLimit")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "AccessKeyID is required")
+	assert.ErrorContains(t, err, "Bucket is required")
+	assert.ErrorContains(t, err, "Region is required for s3")
+
+	cfg.AccessKeyID, cfg.AccessKeySecret, cfg.Region = "ak", "sk", "cn-north-1"
+	cfg.Buckets = map[string]BucketOverride{
+		"fileContent":   {Bucket: "file-content", EnableCompressor: Bool(true), CompressType: "lzma"},
+		"fileSnapshots": {},
+	}
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, `bucket "fileContent": CompressType "lzma" is unknown`)
+	assert.ErrorContains(t, err, `bucket "fileSnapshots": Bucket is required`)
+
+	_, err = New(&Options{StorageType: "s3", Bucket: "test", Region: "cn-north-1", EnableCompressor: true, CompressType: "lzma"})
+	assert.ErrorContains(t, err, "lzma")
+}
+
+func TestConfig_bucket(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SSL = true
+	cfg.Buckets = map[string]BucketOverride{
+		"fileContent":   {Bucket: "file-content"},
+		"fileSnapshots": {Bucket: "file-snapshots", SSL: Bool(false), EnableMetricInterceptor: Bool(false), NotFoundAsNil: Bool(true)},
+	}
+
+	content, err := cfg.bucket("fileContent")
+	assert.NoError(t, err)
+	assert.True(t, content.SSL)
+	assert.True(t, content.EnableMetricInterceptor)
+	assert.False(t, content.NotFoundAsNil)
+	assert.False(t, content.Options().DisableMetricInterceptor)
+
+	snapshots, err := cfg.bucket("fileSnapshots")
+	assert.NoError(t, err)
+	assert.False(t, snapshots.SSL)
+	assert.False(t, snapshots.EnableMetricInterceptor)
+	assert.True(t, snapshots.EnableTraceInterceptor)
+	assert.True(t, snapshots.NotFoundAsNil)
+	assert.True(t, snapshots.Options().DisableMetricInterceptor)
+	assert.NotEqual(t, content.transportKey(), snapshots.transportKey())
+}
@@ -0,0 +1,94 @@
+package awos
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Manager creates a Client for each bucket of Config.Buckets on first use,
+// the buckets with the same endpoint and credentials share the oss client or the s3 session, and so their connections
+type Manager struct {
+	cfg *Config
+
+	mu         sync.Mutex
+	clients    map[string]Client
+	ossClients map[string]*oss.Client
+	s3Sessions map[string]*session.Session
+}
+
+// NewManager returns the Manager of the buckets of cfg, e.g.
+//
+//	cfg := awos.DefaultConfig()
+//	cfg.AccessKeyID, cfg.AccessKeySecret, cfg.Endpoint = ak, sk, endpoint
+//	cfg.Buckets = map[string]awos.BucketOverride{"fileContent": {Bucket: "file-content", Shards: shards}}
+//	client, err := awos.NewManager(cfg).Bucket("fileContent")
+func NewManager(cfg *Config) *Manager {
+	return &Manager{
+		cfg:        cfg,
+		clients:    make(map[string]Client),
+		ossClients: make(map[string]*oss.Client),
+		s3Sessions: make(map[string]*session.Session),
+	}
+}
+
+// Bucket returns the Client of the bucket named name in Config.Buckets, it's created on the first call
+func (m *Manager) Bucket(name string) (Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if client, ok := m.clients[name]; ok {
+		return client, nil
+	}
+	cfg, err := m.cfg.bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := m.newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m.clients[name] = client
+	return client, nil
+}
+
+// Names returns the sorted names of the buckets in Config.Buckets
+func (m *Manager) Names() []string {
+	res := make([]string, 0, len(m.cfg.Buckets))
+	for name := range m.cfg.Buckets {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (m *Manager) newClient(cfg *Config) (Client, error) {
+	options := cfg.Options()
+	key := cfg.transportKey()
+	switch cfg.StorageType {
+	case StorageTypeOSS:
+		client, ok := m.ossClients[key]
+		if !ok {
+			var err error
+			client, err = newOSSClient(cfg, options)
+			if err != nil {
+				return nil, err
+			}
+			m.ossClients[key] = client
+		}
+		return newOSS(cfg, options, client)
+	case StorageTypeS3:
+		sess, ok := m.s3Sessions[key]
+		if !ok {
+			sess = newS3Session(cfg, options)
+			m.s3Sessions[key] = sess
+		}
+		return newS3(cfg, options, sess)
+	case StorageTypeFile:
+		return newFile(cfg, options)
+	default:
+		return nil, fmt.Errorf(`unknown StorageType:"%s" of bucket %q, only supports oss, s3 or file`, cfg.StorageType, cfg.bucketKey)
+	}
+}
@@ -0,0 +1,76 @@
+package awos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			var mu sync.Mutex
+			var paths []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths = append(paths, r.Host+r.URL.Path)
+				mu.Unlock()
+				fmt.Fprint(w, "content")
+			}))
+			defer server.Close()
+
+			cfg := DefaultConfig()
+			cfg.StorageType = strings.ToUpper(storageType)
+			cfg.AccessKeyID = "ak"
+			cfg.AccessKeySecret = "sk"
+			cfg.Endpoint = server.URL
+			cfg.Region = "cn-north-1"
+			cfg.S3ForcePathStyle = true
+			cfg.Buckets = map[string]BucketOverride{
+				"fileContent":   {Bucket: "file-content", Shards: []string{"y", "z"}},
+				"fileSnapshots": {Bucket: "file-snapshots", NotFoundAsNil: Bool(true)},
+				"other":         {Bucket: "other", AccessKeyID: "ak2", AccessKeySecret: "sk2"},
+			}
+			manager := NewManager(cfg)
+			assert.Equal(t, []string{"fileContent", "fileSnapshots", "other"}, manager.Names())
+
+			content, err := manager.Bucket("fileContent")
+			assert.NoError(t, err)
+			again, err := manager.Bucket("fileContent")
+			assert.NoError(t, err)
+			assert.True(t, content == again)
+			snapshots, err := manager.Bucket("fileSnapshots")
+			assert.NoError(t, err)
+			other, err := manager.Bucket("other")
+			assert.NoError(t, err)
+
+			res, err := content.Get("key")
+			assert.NoError(t, err)
+			assert.Equal(t, "content", res)
+			_, err = snapshots.Get("key")
+			assert.NoError(t, err)
+
+			switch storageType {
+			case StorageTypeS3:
+				assert.Equal(t, []string{server.Listener.Addr().String() + "/file-content-y/key", server.Listener.Addr().String() + "/file-snapshots/key"}, paths)
+				assert.True(t, content.(*S3).Client.Config.HTTPClient == snapshots.(*S3).Client.Config.HTTPClient)
+				assert.False(t, content.(*S3).Client.Config.HTTPClient == other.(*S3).Client.Config.HTTPClient)
+				assert.True(t, snapshots.(*S3).notFoundAsNil())
+				assert.False(t, content.(*S3).notFoundAsNil())
+			case StorageTypeOSS:
+				assert.Equal(t, "file-content-y", content.(*OSS).Shards["y"].BucketName)
+				assert.True(t, content.(*OSS).Shards["y"].Client.HTTPClient == snapshots.(*OSS).Bucket.Client.HTTPClient)
+				assert.False(t, content.(*OSS).Shards["y"].Client.HTTPClient == other.(*OSS).Bucket.Client.HTTPClient)
+				assert.True(t, snapshots.(*OSS).notFoundAsNil())
+			}
+
+			_, err = manager.Bucket("sheetHistories")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryBucket is the bucket name of the errors returned by Memory
+const memoryBucket = "memory"
+
+// Memory is a Client keeping the objects in memory, e.g. for the unit tests of the users of awos instead of a mock.
+// The metadata is returned with the same key casing as s3, and missing objects return ErrNotFound.
+// It is safe for concurrent use.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+}
+
+type memoryObject struct {
+	data               []byte
+	meta               map[string]string
+	contentType        string
+	contentEncoding    *string
+	contentDisposition *string
+	etag               string
+	lastModified       time.Time
+}
+
+var _ Client = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory Client
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]*memoryObject)}
+}
+
+func (m *Memory) object(ctx context.Context, op string, key string) (*memoryObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	object, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, &Error{
+			StorageType: StorageTypeMemory,
+			Op:          op,
+			Bucket:      memoryBucket,
+			Key:         key,
+			StatusCode:  http.StatusNotFound,
+			Code:        "NoSuchKey",
+			Err:         errors.New("the specified key does not exist"),
+		}
+	}
+	return object, nil
+}
+
+func (object *memoryObject) headers(options []GetOptions) map[string]*string {
+	return objectHeaders(object.meta, int64(len(object.data)), object.contentType, object.contentEncoding, object.contentDisposition, options)
+}
+
+func (m *Memory) Get(key string, options ...GetOptions) (string, error) {
+	return m.GetWithContext(context.Background(), key, options...)
+}
+
+func (m *Memory) GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := m.GetBytesWithContext(ctx, key, options...)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (m *Memory) GetBytes(key string, options ...GetOptions) ([]byte, error) {
+	return m.GetBytesWithContext(context.Background(), key, options...)
+}
+
+func (m *Memory) GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, object.data...), nil
+}
+
+func (m *Memory) GetAsReader(key string, options ...GetOptions) (io.ReadCloser, error) {
+	return m.GetAsReaderWithContext(context.Background(), key, options...)
+}
+
+func (m *Memory) GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+func (m *Memory) GetWithMeta(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return m.GetWithMetaWithContext(context.Background(), key, attributes, options...)
+}
+
+func (m *Memory) GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.data)), getS3Meta(attributes, object.headers(options)), nil
+}
+
+// GetWithMetaGZIP is GetWithMeta, the content is returned as stored like a server ignoring Accept-Encoding
+func (m *Memory) GetWithMetaGZIP(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return m.GetWithMetaGZIPWithContext(context.Background(), key, attributes, options...)
+}
+
+func (m *Memory) GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return m.GetWithMetaWithContext(ctx, key, attributes, options...)
+}
+
+func (m *Memory) Put(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return m.PutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (m *Memory) PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	var data []byte
+	if reader != nil {
+		var err error
+		data, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+	}
+	m.put(key, data, meta, putOptions)
+	return nil
+}
+
+func (m *Memory) put(key string, data []byte, meta map[string]string, putOptions *putOptions) {
+	object := &memoryObject{
+		data:               data,
+		meta:               make(map[string]string, len(meta)),
+		contentType:        putOptions.contentType,
+		contentEncoding:    putOptions.contentEncoding,
+		contentDisposition: putOptions.contentDisposition,
+		lastModified:       time.Now(),
+	}
+	for k, v := range meta {
+		object.meta[k] = v
+	}
+	sum := md5.Sum(data)
+	object.etag = hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	m.objects[key] = object
+	m.mu.Unlock()
+}
+
+func (m *Memory) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return m.CompressAndPutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (m *Memory) CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	body, _, _, err := compressForPut(putOptions.compressType, reader)
+	if err != nil {
+		return err
+	}
+	compressed := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		compressed[k] = v
+	}
+	compressed["Compressor"] = putOptions.compressType
+	return m.PutWithContext(ctx, key, body, compressed, options...)
+}
+
+func (m *Memory) GetAndDecompress(key string) (string, error) {
+	return m.GetAndDecompressWithContext(context.Background(), key)
+}
+
+func (m *Memory) GetAndDecompressWithContext(ctx context.Context, key string) (string, error) {
+	body, err := m.GetAndDecompressAsReaderWithContext(ctx, key)
+	if err != nil || body == nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (m *Memory) GetAndDecompressAsReader(key string) (io.ReadCloser, error) {
+	return m.GetAndDecompressAsReaderWithContext(context.Background(), key)
+}
+
+func (m *Memory) GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return nil, err
+	}
+	compressor, encoding := compressionOf(object.headers(nil))
+	return decompressBody(ioutil.NopCloser(bytes.NewReader(object.data)), compressor, encoding)
+}
+
+// Range returns length bytes from offset, the range is cut at the end of the object like s3
+func (m *Memory) Range(key string, offset int64, length int64) (io.ReadCloser, error) {
+	return m.RangeWithContext(context.Background(), key, offset, length)
+}
+
+func (m *Memory) RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(object.data))
+	if offset < 0 || length <= 0 || offset >= size {
+		return nil, &Error{
+			StorageType: StorageTypeMemory,
+			Op:          "GetObject",
+			Bucket:      memoryBucket,
+			Key:         key,
+			StatusCode:  http.StatusRequestedRangeNotSatisfiable,
+			Code:        "InvalidRange",
+			Err:         fmt.Errorf("range %d-%d is not satisfiable for size %d", offset, offset+length-1, size),
+		}
+	}
+	end := offset + length
+	if end > size {
+		end = size
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.data[offset:end])), nil
+}
+
+// Del deletes the object, deleting a missing object is not an error like s3
+func (m *Memory) Del(key string) error {
+	return m.DelWithContext(context.Background(), key)
+}
+
+func (m *Memory) DelWithContext(ctx context.Context, key string) error {
+	return m.DelMultiWithContext(ctx, []string{key})
+}
+
+func (m *Memory) DelMulti(keys []string) error {
+	return m.DelMultiWithContext(context.Background(), keys)
+}
+
+func (m *Memory) DelMultiWithContext(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.objects, key)
+	}
+	return nil
+}
+
+func (m *Memory) Head(key string, attributes []string) (map[string]string, error) {
+	return m.HeadWithContext(context.Background(), key, attributes)
+}
+
+func (m *Memory) HeadWithContext(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	object, err := m.object(ctx, "HeadObject", key)
+	if err != nil {
+		return nil, err
+	}
+	return getS3Meta(attributes, object.headers(nil)), nil
+}
+
+func (m *Memory) Exists(key string) (bool, error) {
+	return m.ExistsWithContext(context.Background(), key)
+}
+
+func (m *Memory) ExistsWithContext(ctx context.Context, key string) (bool, error) {
+	_, err := m.object(ctx, "HeadObject", key)
+	if err == nil {
+		return true, nil
+	}
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (m *Memory) ListObject(key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return m.ListObjectWithContext(context.Background(), key, prefix, marker, maxKeys, delimiter)
+}
+
+// ListObjectWithContext returns the keys after marker, the keys grouped into common prefixes by the delimiter are left out like s3
+func (m *Memory) ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	result, err := m.List(ctx, ListInput{Prefix: prefix, Marker: marker, MaxKeys: maxKeys, Delimiter: delimiter})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(result.Objects))
+	for _, object := range result.Objects {
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
+}
+
+// List returns a page of objects in key order, the NextContinuationToken is the last key or common prefix of the page
+func (m *Memory) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	return pageKeys(keys, input, func(key string) (ObjectInfo, error) {
+		object := m.objects[key]
+		return ObjectInfo{
+			Key:          key,
+			Size:         int64(len(object.data)),
+			ETag:         object.etag,
+			LastModified: object.lastModified,
+			StorageClass: "STANDARD",
+		}, nil
+	})
+}
+
+// SignURL returns a memory:// url of the key, it can't be fetched and is only meant to be compared in the tests
+func (m *Memory) SignURL(key string, expired int64, options ...SignOptions) (string, error) {
+	signOptions := DefaultSignOptions()
+	for _, opt := range options {
+		opt(signOptions)
+	}
+	query := url.Values{}
+	query.Set("Expires", strconv.FormatInt(time.Now().Unix()+expired, 10))
+	if signOptions.process != nil {
+		query.Set("x-oss-process", *signOptions.process)
+	}
+	u := url.URL{Scheme: StorageTypeMemory, Host: memoryBucket, Path: "/" + key, RawQuery: query.Encode()}
+	return u.String(), nil
+}
+
+// Upload is Put of the whole content, there are no parts in memory
+func (m *Memory) Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return m.UploadWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (m *Memory) UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return m.PutWithContext(ctx, key, bytes.NewReader(data), meta, getUploadOptions(options).putOptions...)
+}
+
+func (m *Memory) Download(key string, w io.WriterAt, options ...DownloadOptions) error {
+	return m.DownloadWithContext(context.Background(), key, w, options...)
+}
+
+func (m *Memory) DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error {
+	object, err := m.object(ctx, "GetObject", key)
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteAt(object.data, 0)
+	return err
+}
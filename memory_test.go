@@ -0,0 +1,164 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Meta(t *testing.T) {
+	client := NewMemory()
+	err := client.Put("key", strings.NewReader("content"), map[string]string{"test-key": "value", "camelKey": "camel"},
+		PutWithContentType("application/json"), PutWithContentEncoding("gzip"))
+	assert.NoError(t, err)
+
+	res, err := client.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "content", res)
+
+	meta, err := client.Head("key", []string{"test-key", "camelKey", "Content-Type", "Content-Encoding", "Content-Length"})
+	assert.NoError(t, err)
+	// the metadata keys are canonicalized by the s3 sdk, so camelKey can't be found like s3
+	assert.Equal(t, map[string]string{
+		"test-key":         "value",
+		"Content-Type":     "application/json",
+		"Content-Encoding": "gzip",
+		"Content-Length":   "7",
+	}, meta)
+
+	body, meta, err := client.GetWithMeta("key", []string{"Content-Type"}, GetWithContentType("text/html"))
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, "text/html", meta["Content-Type"])
+
+	_, err = client.Get("missing")
+	assert.True(t, IsNotFound(err))
+	ok, err := client.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, client.DelMulti([]string{"key", "missing"}))
+	ok, err = client.Exists("key")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemory_Range(t *testing.T) {
+	client := NewMemory()
+	assert.NoError(t, client.Put("key", strings.NewReader("0123456789"), nil))
+
+	r, err := client.Range("key", 2, 3)
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "234", string(data))
+
+	r, err = client.Range("key", 8, 5)
+	assert.NoError(t, err)
+	data, _ = ioutil.ReadAll(r)
+	assert.Equal(t, "89", string(data))
+
+	_, err = client.Range("key", 10, 1)
+	assert.Error(t, err)
+}
+
+func TestMemory_List(t *testing.T) {
+	client := NewMemory()
+	for _, key := range []string{"a/1", "a/2", "b", "c/1", "c/2/3", "d"} {
+		assert.NoError(t, client.Put(key, strings.NewReader(key), nil))
+	}
+
+	keys, err := client.ListObject("", "", "", 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/1", "a/2", "b", "c/1", "c/2/3", "d"}, keys)
+
+	keys, err = client.ListObject("", "", "b", 0, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, keys)
+
+	keys, err = client.ListObject("", "c/", "", 0, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c/1"}, keys)
+
+	var objects, prefixes []string
+	input := ListInput{MaxKeys: 1, Delimiter: "/"}
+	for {
+		result, err := client.List(context.Background(), input)
+		assert.NoError(t, err)
+		for _, object := range result.Objects {
+			objects = append(objects, object.Key)
+		}
+		prefixes = append(prefixes, result.CommonPrefixes...)
+		if !result.IsTruncated {
+			break
+		}
+		input.ContinuationToken = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"b", "d"}, objects)
+	assert.Equal(t, []string{"a/", "c/"}, prefixes)
+}
+
+func TestMemory_CompressAndPut(t *testing.T) {
+	client := NewMemory()
+	content := strings.Repeat("content", 100)
+	assert.NoError(t, client.CompressAndPut("key", strings.NewReader(content), map[string]string{"test-key": "value"}))
+
+	raw, err := client.GetBytes("key")
+	assert.NoError(t, err)
+	assert.Less(t, len(raw), len(content))
+
+	res, err := client.GetAndDecompress("key")
+	assert.NoError(t, err)
+	assert.Equal(t, content, res)
+
+	meta, err := client.Head("key", []string{MetaCompressor, "test-key"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{MetaCompressor: "snappy", "test-key": "value"}, meta)
+}
+
+func TestMemory_CompressAndPutWithCompressType(t *testing.T) {
+	client := NewMemory()
+	content := strings.Repeat("content", 100)
+	for _, compressType := range []string{"gzip", "zstd", "lz4", "br"} {
+		assert.NoError(t, client.CompressAndPut(compressType, strings.NewReader(content), nil, PutWithCompressType(compressType)))
+		res, err := client.GetAndDecompress(compressType)
+		assert.NoError(t, err)
+		assert.Equal(t, content, res)
+		meta, err := client.Head(compressType, []string{MetaCompressor})
+		assert.NoError(t, err)
+		assert.Equal(t, compressType, meta[MetaCompressor])
+	}
+
+	assert.Error(t, client.CompressAndPut("lzma", strings.NewReader(content), nil, PutWithCompressType("lzma")))
+}
+
+func TestMemory_SignURL(t *testing.T) {
+	client := NewMemory()
+	res, err := client.SignURL("dir/key", 60, SignWithProcess("image/resize,w_100"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(res, "memory://memory/dir/key?"))
+	assert.Contains(t, res, "x-oss-process=image%2Fresize%2Cw_100")
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	client := NewMemory()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i%4)
+			assert.NoError(t, client.Put(key, bytes.NewReader([]byte(key)), nil))
+			_, _ = client.Get(key)
+			_, _ = client.ListObject("", "key-", "", 0, "")
+			_ = client.Del(key)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,164 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "awos"
+
+var metricLabels = []string{"storage_type", "bucket", "operation"}
+
+var metrics = newMetricCollector()
+
+// MetricCollector returns the prometheus.Collector of the requests of every client with EnableMetricInterceptor,
+// register it once, e.g. prometheus.MustRegister(awos.MetricCollector())
+func MetricCollector() prometheus.Collector {
+	return metrics
+}
+
+type metricCollector struct {
+	requests         *prometheus.CounterVec
+	duration         *prometheus.HistogramVec
+	errors           *prometheus.CounterVec
+	sentBytes        *prometheus.CounterVec
+	receivedBytes    *prometheus.CounterVec
+	compressionRatio *prometheus.HistogramVec
+}
+
+func newMetricCollector() *metricCollector {
+	return &metricCollector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "requests_total",
+			Help:      "Number of requests sent to oss/s3, retries included.",
+		}, metricLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests sent to oss/s3 until the response headers are received.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, metricLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "request_errors_total",
+			Help:      "Number of failed requests by the error code of the service or the http status.",
+		}, append(metricLabels, "code")),
+		sentBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "sent_bytes_total",
+			Help:      "Bytes of the request bodies sent to oss/s3.",
+		}, metricLabels),
+		receivedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "received_bytes_total",
+			Help:      "Bytes of the response bodies read from oss/s3.",
+		}, metricLabels),
+		compressionRatio: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "compression_ratio",
+			Help:      "Compressed size divided by the original size of the objects compressed before put.",
+			Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+		}, append(metricLabels, "compressor")),
+	}
+}
+
+func (m *metricCollector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.duration, m.errors, m.sentBytes, m.receivedBytes, m.compressionRatio}
+}
+
+func (m *metricCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (m *metricCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// metricInterceptor records the count, latency and errors of every request
+func metricInterceptor(ctx context.Context, info *requestInfo, next func(ctx context.Context) error) error {
+	start := time.Now()
+	err := next(ctx)
+	metrics.requests.WithLabelValues(info.StorageType, info.Bucket, info.Op).Inc()
+	metrics.duration.WithLabelValues(info.StorageType, info.Bucket, info.Op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.errors.WithLabelValues(info.StorageType, info.Bucket, info.Op, errorCode(err)).Inc()
+	}
+	return err
+}
+
+// errorCode returns the label of the error, the code of the service if any
+func errorCode(err error) string {
+	var awosErr *Error
+	if errors.As(err, &awosErr) {
+		if awosErr.Code != "" {
+			return awosErr.Code
+		}
+		if awosErr.StatusCode != 0 {
+			return strconv.Itoa(awosErr.StatusCode)
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return "Canceled"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "DeadlineExceeded"
+	}
+	return "RequestError"
+}
+
+// observeCompression records the ratio of an object compressed before put
+func (c *Config) observeCompression(storageType string, bucket string, op string, compressor string, size int64, compressedSize int64) {
+	if c == nil || !c.EnableMetricInterceptor || size <= 0 {
+		return
+	}
+	metrics.compressionRatio.WithLabelValues(storageType, bucket, op, compressor).Observe(float64(compressedSize) / float64(size))
+}
+
+// metricTransport records the bytes of the request and response bodies, labeled by the requestInfo of the context
+type metricTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	info := requestInfoFromContext(req.Context())
+	if info == nil {
+		return t.base.RoundTrip(req)
+	}
+	if req.ContentLength > 0 {
+		metrics.sentBytes.WithLabelValues(info.StorageType, info.Bucket, info.Op).Add(float64(req.ContentLength))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &countingReadCloser{
+		ReadCloser: resp.Body,
+		counter:    metrics.receivedBytes.WithLabelValues(info.StorageType, info.Bucket, info.Op),
+	}
+	return resp, nil
+}
+
+// countingReadCloser adds the bytes read to the counter, the body may be read long after the request returns
+type countingReadCloser struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package awos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMetricClient(t *testing.T, storageType string, bucket string, enable bool) (Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		fmt.Fprint(w, "content")
+	}))
+	client, err := New(&Options{
+		StorageType:              storageType,
+		AccessKeyID:              "ak",
+		AccessKeySecret:          "sk",
+		Endpoint:                 server.URL,
+		Bucket:                   bucket,
+		Region:                   "cn-north-1",
+		S3ForcePathStyle:         true,
+		RetryPolicy:              &RetryPolicy{MaxAttempts: 1},
+		DisableMetricInterceptor: !enable,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
+
+func TestMetrics(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			bucket := "metrics-" + storageType
+			client, closeFn := newMetricClient(t, storageType, bucket, true)
+			defer closeFn()
+
+			res, err := client.Get("key")
+			assert.NoError(t, err)
+			assert.Equal(t, "content", res)
+			assert.NoError(t, client.Put("key", strings.NewReader("hello"), nil))
+			_, err = client.Get("missing")
+			assert.True(t, IsNotFound(err))
+
+			assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues(storageType, bucket, "GetObject")))
+			assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues(storageType, bucket, "PutObject")))
+			assert.Equal(t, float64(1), testutil.ToFloat64(metrics.errors.WithLabelValues(storageType, bucket, "GetObject", "NoSuchKey")))
+			assert.Equal(t, float64(5), testutil.ToFloat64(metrics.sentBytes.WithLabelValues(storageType, bucket, "PutObject")))
+			assert.GreaterOrEqual(t, testutil.ToFloat64(metrics.receivedBytes.WithLabelValues(storageType, bucket, "GetObject")), float64(len("content")))
+		})
+	}
+}
+
+func TestMetrics_Disabled(t *testing.T) {
+	bucket := "metrics-disabled"
+	client, closeFn := newMetricClient(t, StorageTypeS3, bucket, false)
+	defer closeFn()
+
+	count := testutil.CollectAndCount(metrics.requests)
+	_, err := client.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, count, testutil.CollectAndCount(metrics.requests))
+}
@@ -0,0 +1,107 @@
+package awos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxUploadParts is the maximum number of parts of a multipart upload, both oss and s3 limit it to 10000
+const maxUploadParts = 10000
+
+func getUploadOptions(options []UploadOptions) *uploadOptions {
+	uploadOpts := DefaultUploadOptions()
+	for _, opt := range options {
+		opt(uploadOpts)
+	}
+	if uploadOpts.partSize < MinPartSize {
+		uploadOpts.partSize = MinPartSize
+	}
+	if uploadOpts.concurrency <= 0 {
+		uploadOpts.concurrency = 1
+	}
+	if uploadOpts.partAttempts == 0 {
+		uploadOpts.partAttempts = 1
+	}
+	return uploadOpts
+}
+
+// readPart reads at most size bytes, the returned bool reports whether the reader has been drained
+func readPart(reader io.Reader, size int64) ([]byte, bool, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return buf[:n], true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return buf, false, nil
+}
+
+// uploadParts uploads first and the rest of reader part by part, at most opts.concurrency parts at the same time.
+// Every part is retried on its own by policy with opts.partAttempts, the first failed part cancels the others.
+func uploadParts(ctx context.Context, first []byte, reader io.Reader, opts *uploadOptions, policy *RetryPolicy,
+	upload func(ctx context.Context, partNumber int, part []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	partPolicy := policy.withAttempts(int(opts.partAttempts))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, opts.concurrency)
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	part, drained := first, false
+	for partNumber := 1; ; partNumber++ {
+		if partNumber > maxUploadParts {
+			setErr(fmt.Errorf("too many parts, the object is larger than %d parts of %d bytes", maxUploadParts, opts.partSize))
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			setErr(ctx.Err())
+			break
+		}
+
+		wg.Add(1)
+		go func(partNumber int, part []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := partPolicy.do(ctx, true, func(ctx context.Context) error {
+				return upload(ctx, partNumber, part)
+			})
+			if err != nil {
+				setErr(fmt.Errorf("upload part %d failed: %w", partNumber, err))
+			}
+		}(partNumber, part)
+
+		if drained {
+			break
+		}
+		var err error
+		part, drained, err = readPart(reader, opts.partSize)
+		if err != nil {
+			setErr(err)
+			break
+		}
+		if len(part) == 0 {
+			break
+		}
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,54 @@
+package awos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadParts(t *testing.T) {
+	opts := &uploadOptions{partSize: 4, concurrency: 2, partAttempts: 2}
+	reader := strings.NewReader("0123456789")
+	first, drained, err := readPart(reader, opts.partSize)
+	assert.NoError(t, err)
+	assert.False(t, drained)
+
+	var mu sync.Mutex
+	parts := make(map[int]string)
+	var failed int32
+	err = uploadParts(context.Background(), first, reader, opts, nil, func(ctx context.Context, partNumber int, part []byte) error {
+		// the first attempt of part 2 fails and must be retried
+		if partNumber == 2 && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return errors.New("broken pipe")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		parts[partNumber] = string(part)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "0123", 2: "4567", 3: "89"}, parts)
+}
+
+func TestUploadParts_Error(t *testing.T) {
+	opts := &uploadOptions{partSize: 1, concurrency: 2, partAttempts: 1}
+	reader := bytes.NewReader(bytes.Repeat([]byte("a"), 100))
+	first, _, _ := readPart(reader, opts.partSize)
+
+	var uploaded int32
+	err := uploadParts(context.Background(), first, reader, opts, nil, func(ctx context.Context, partNumber int, part []byte) error {
+		if partNumber == 3 {
+			return errors.New("internal error")
+		}
+		atomic.AddInt32(&uploaded, 1)
+		return nil
+	})
+	assert.EqualError(t, err, "upload part 3 failed: internal error")
+	assert.Less(t, atomic.LoadInt32(&uploaded), int32(99))
+}
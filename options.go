@@ -8,6 +8,7 @@ type putOptions struct {
 	contentDisposition *string
 	cacheControl       *string
 	expires            *time.Time
+	compressType       string
 }
 
 type PutOptions func(options *putOptions)
@@ -42,9 +43,17 @@ func PutWithExpireTime(expires time.Time) PutOptions {
 	}
 }
 
+// PutWithCompressType sets the registered compressor of CompressAndPut, snappy by default
+func PutWithCompressType(compressType string) PutOptions {
+	return func(options *putOptions) {
+		options.compressType = compressType
+	}
+}
+
 func DefaultPutOptions() *putOptions {
 	return &putOptions{
-		contentType: "text/plain",
+		contentType:  "text/plain",
+		compressType: compressTypeSnappy,
 	}
 }
 
@@ -52,6 +61,7 @@ type getOptions struct {
 	contentType         *string
 	contentEncoding     *string
 	enableCRCValidation bool
+	raw                 bool
 }
 
 func DefaultGetOptions() *getOptions {
@@ -78,6 +88,13 @@ func EnableCRCValidation() GetOptions {
 	}
 }
 
+// GetRaw returns the content as stored, without the decoding of EnableDecompressor
+func GetRaw() GetOptions {
+	return func(options *getOptions) {
+		options.raw = true
+	}
+}
+
 type SignOptions func(options *signOptions)
 
 func SignWithProcess(process string) SignOptions {
@@ -93,3 +110,104 @@ type signOptions struct {
 func DefaultSignOptions() *signOptions {
 	return &signOptions{}
 }
+
+const (
+	// MinPartSize is the minimum size of a multipart upload part except the last one (limited by s3)
+	MinPartSize = int64(5 << 20)
+	// DefaultPartSize default size of a multipart upload part
+	DefaultPartSize = int64(8 << 20)
+	// DefaultUploadConcurrency default number of parts uploaded at the same time
+	DefaultUploadConcurrency = 4
+	// DefaultPartAttempts default attempts of uploading a single part
+	DefaultPartAttempts = uint(3)
+)
+
+type uploadOptions struct {
+	partSize     int64
+	concurrency  int
+	partAttempts uint
+	putOptions   []PutOptions
+}
+
+type UploadOptions func(options *uploadOptions)
+
+// UploadWithPartSize set the size of every part except the last one, values less than MinPartSize are raised to MinPartSize
+func UploadWithPartSize(partSize int64) UploadOptions {
+	return func(options *uploadOptions) {
+		options.partSize = partSize
+	}
+}
+
+// UploadWithConcurrency set the number of parts uploaded at the same time
+func UploadWithConcurrency(concurrency int) UploadOptions {
+	return func(options *uploadOptions) {
+		options.concurrency = concurrency
+	}
+}
+
+// UploadWithPartAttempts set the attempts of uploading a single part
+func UploadWithPartAttempts(attempts uint) UploadOptions {
+	return func(options *uploadOptions) {
+		options.partAttempts = attempts
+	}
+}
+
+// UploadWithPutOptions set content type, content encoding, etc. of the uploaded object
+func UploadWithPutOptions(putOptions ...PutOptions) UploadOptions {
+	return func(options *uploadOptions) {
+		options.putOptions = append(options.putOptions, putOptions...)
+	}
+}
+
+func DefaultUploadOptions() *uploadOptions {
+	return &uploadOptions{
+		partSize:     DefaultPartSize,
+		concurrency:  DefaultUploadConcurrency,
+		partAttempts: DefaultPartAttempts,
+	}
+}
+
+type downloadOptions struct {
+	partSize     int64
+	concurrency  int
+	partAttempts uint
+	noVerify     bool
+}
+
+type DownloadOptions func(options *downloadOptions)
+
+// DownloadWithPartSize set the size of every range
+func DownloadWithPartSize(partSize int64) DownloadOptions {
+	return func(options *downloadOptions) {
+		options.partSize = partSize
+	}
+}
+
+// DownloadWithConcurrency set the number of ranges fetched at the same time
+func DownloadWithConcurrency(concurrency int) DownloadOptions {
+	return func(options *downloadOptions) {
+		options.concurrency = concurrency
+	}
+}
+
+// DownloadWithPartAttempts set the attempts of fetching a single range
+func DownloadWithPartAttempts(attempts uint) DownloadOptions {
+	return func(options *downloadOptions) {
+		options.partAttempts = attempts
+	}
+}
+
+// DownloadWithoutVerification skip checking the downloaded content against the stored ETag/CRC64
+func DownloadWithoutVerification() DownloadOptions {
+	return func(options *downloadOptions) {
+		options.noVerify = true
+	}
+}
+
+func DefaultDownloadOptions() *downloadOptions {
+	return &downloadOptions{
+		partSize:     DefaultPartSize,
+		concurrency:  DefaultUploadConcurrency,
+		partAttempts: DefaultPartAttempts,
+	}
+}
@@ -2,37 +2,75 @@ package awos
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
-	"github.com/avast/retry-go"
-	"github.com/golang/snappy"
 )
 
 var _ Client = (*OSS)(nil)
+var _ BucketClient = (*OSS)(nil)
+
+// ossCopyObjectLimit is the max size of an object copied by a single CopyObject
+const ossCopyObjectLimit = int64(1 << 30)
 
 type OSS struct {
-	Bucket     *oss.Bucket
-	Shards     map[string]*oss.Bucket
-	compressor Compressor
-	cfg        *config
+	Bucket *oss.Bucket
+	// Shards shard => bucket, keyed by the last character of the key when ShardRouter is nil
+	Shards       map[string]*oss.Bucket
+	ShardRouter  ShardRouter
+	compressor   Compressor
+	cfg          *Config
+	retryPolicy  *RetryPolicy
+	interceptors []interceptor
 }
 
 func (ossClient *OSS) GetWithMetaGZIP(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
-	// TODO implement me
-	panic("implement me")
+	return ossClient.GetWithMetaGZIPWithContext(context.Background(), key, attributes, options...)
+}
+
+func (ossClient *OSS) GetWithMetaGZIPWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	bucket, err := ossClient.getBucket(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	// the body is returned as the server sends it, gzip if it compresses the object
+	ossOpts := append(ossClient.getOSSOptions(getOpts), oss.AcceptEncoding("gzip"))
+
+	var result *oss.GetObjectResult
+	err = ossClient.do(ctx, "GetObject", bucket.BucketName, key, true, func(ctx context.Context) error {
+		result, err = bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, append(ossOpts, oss.WithContext(ctx)))
+		return err
+	})
+	if err != nil {
+		if ossClient.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return result.Response, getOSSMeta(attributes, result.Response.Headers), nil
 }
 
 func (ossClient *OSS) getBucket(key string) (*oss.Bucket, error) {
 	if ossClient.Shards != nil && len(ossClient.Shards) > 0 {
-		keyLength := len(key)
-		bucket := ossClient.Shards[strings.ToLower(key[keyLength-1:keyLength])]
+		shard, err := routeShard(ossClient.ShardRouter, key)
+		if err != nil {
+			return nil, err
+		}
+		bucket := ossClient.Shards[shard]
 		if bucket == nil {
 			return nil, errors.New("shards can't find bucket")
 		}
@@ -45,35 +83,33 @@ func (ossClient *OSS) getBucket(key string) (*oss.Bucket, error) {
 
 // don't forget to call the close() method of the io.ReadCloser
 func (ossClient *OSS) GetAsReader(key string, options ...GetOptions) (io.ReadCloser, error) {
-	bucket, err := ossClient.getBucket(key)
-	if err != nil {
-		return nil, err
-	}
+	return ossClient.GetAsReaderWithContext(context.Background(), key, options...)
+}
 
+func (ossClient *OSS) GetAsReaderWithContext(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
 	}
-	readCloser, err := bucket.GetObject(key, getOSSOptions(getOpts)...)
-	if err != nil {
-		if oerr, ok := err.(oss.ServiceError); ok {
-			if oerr.StatusCode == 404 {
-				return nil, nil
-			}
-		}
+	result, err := ossClient.get(ctx, key, getOpts)
+	if err != nil || result == nil {
 		return nil, err
 	}
 
-	return readCloser, nil
+	return ossClient.cfg.decodeBody(result.Response, result.Response.Headers.Get(oss.HTTPHeaderContentEncoding), getOpts)
 }
 
 // don't forget to call the close() method of the io.ReadCloser
 func (ossClient *OSS) GetWithMeta(key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return ossClient.GetWithMetaWithContext(context.Background(), key, attributes, options...)
+}
+
+func (ossClient *OSS) GetWithMetaWithContext(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
 	}
-	result, err := ossClient.get(key, getOpts)
+	result, err := ossClient.get(ctx, key, getOpts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -81,11 +117,19 @@ func (ossClient *OSS) GetWithMeta(key string, attributes []string, options ...Ge
 		return nil, nil, nil
 	}
 
-	return result.Response.Body, getOSSMeta(attributes, result.Response.Headers), nil
+	body, err := ossClient.cfg.decodeBody(result.Response, result.Response.Headers.Get(oss.HTTPHeaderContentEncoding), getOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, getOSSMeta(attributes, result.Response.Headers), nil
 }
 
 func (ossClient *OSS) Get(key string, options ...GetOptions) (string, error) {
-	data, err := ossClient.GetBytes(key, options...)
+	return ossClient.GetWithContext(context.Background(), key, options...)
+}
+
+func (ossClient *OSS) GetWithContext(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := ossClient.GetBytesWithContext(ctx, key, options...)
 	if err != nil {
 		return "", err
 	}
@@ -93,11 +137,15 @@ func (ossClient *OSS) Get(key string, options ...GetOptions) (string, error) {
 }
 
 func (ossClient *OSS) GetBytes(key string, options ...GetOptions) ([]byte, error) {
+	return ossClient.GetBytesWithContext(context.Background(), key, options...)
+}
+
+func (ossClient *OSS) GetBytesWithContext(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
 	}
-	result, err := ossClient.get(key, getOpts)
+	result, err := ossClient.get(ctx, key, getOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -105,17 +153,18 @@ func (ossClient *OSS) GetBytes(key string, options ...GetOptions) ([]byte, error
 		return nil, nil
 	}
 
-	body := result.Response
-	defer func() {
-		if body != nil {
-			body.Close()
-		}
-	}()
+	body, err := ossClient.cfg.decodeBody(result.Response, result.Response.Headers.Get(oss.HTTPHeaderContentEncoding), getOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
 
 	data, err := ioutil.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
+	// the crc64 is of the stored content, which the decompressor may leave unread at its end
+	_, _ = io.Copy(ioutil.Discard, result.Response)
 
 	if getOpts.enableCRCValidation && result.ServerCRC > 0 && result.ClientCRC.Sum64() != result.ServerCRC {
 		return nil, fmt.Errorf("crc64 check failed, reqId:%s, serverCRC:%d, clientCRC:%d", extractOSSRequestID(result.Response),
@@ -125,70 +174,124 @@ func (ossClient *OSS) GetBytes(key string, options ...GetOptions) ([]byte, error
 }
 
 func (ossClient *OSS) Range(key string, offset int64, length int64) (io.ReadCloser, error) {
-	return ossClient.Bucket.GetObject(key, oss.Range(offset, offset+length-1))
+	return ossClient.RangeWithContext(context.Background(), key, offset, length)
 }
 
-func (ossClient *OSS) GetAndDecompress(key string) (string, error) {
-	result, err := ossClient.get(key, DefaultGetOptions())
+func (ossClient *OSS) RangeWithContext(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	bucket, err := ossClient.getBucket(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if result == nil {
-		return "", nil
+
+	var reader io.ReadCloser
+	err = ossClient.retryPolicy.do(ctx, true, func(ctx context.Context) error {
+		reader, err = ossClient.getRange(ctx, bucket, key, offset, length)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return reader, nil
+}
 
-	body := result.Response
-	defer func() {
-		if body != nil {
-			body.Close()
-		}
-	}()
+// getRange sends a single ranged GetObject, retries are up to the caller
+func (ossClient *OSS) getRange(ctx context.Context, bucket *oss.Bucket, key string, offset int64, length int64) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := ossClient.invoke(ctx, "GetObject", bucket.BucketName, key, func(ctx context.Context) error {
+		var err error
+		// by default oss returns the whole object if the range is not within it
+		reader, err = bucket.GetObject(key, oss.Range(offset, offset+length-1), oss.RangeBehavior("standard"), oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
 
-	compressor := body.Headers.Get("X-Oss-Meta-Compressor")
-	if compressor != "" {
-		if compressor != "snappy" {
-			return "", errors.New("GetAndDecompress only supports snappy for now, got " + compressor)
-		}
+// Download fetches the object by concurrent ranges and writes them to w, e.g. an *os.File.
+// The content is checked against the CRC64 stored by oss.
+func (ossClient *OSS) Download(key string, w io.WriterAt, options ...DownloadOptions) error {
+	return ossClient.DownloadWithContext(context.Background(), key, w, options...)
+}
 
-		rawBytes, err := ioutil.ReadAll(body)
-		if err != nil {
-			return "", err
-		}
+func (ossClient *OSS) DownloadWithContext(ctx context.Context, key string, w io.WriterAt, options ...DownloadOptions) error {
+	bucket, err := ossClient.getBucket(key)
+	if err != nil {
+		return err
+	}
 
-		decodedBytes, err := snappy.Decode(nil, rawBytes)
-		if err != nil {
-			if errors.Is(err, snappy.ErrCorrupt) {
-				reader := snappy.NewReader(bytes.NewReader(rawBytes))
-				data, err := ioutil.ReadAll(reader)
-				if err != nil {
-					return "", err
-				}
-
-				return string(data), nil
-			}
-			return "", err
+	var headers http.Header
+	err = ossClient.do(ctx, "GetObjectMeta", bucket.BucketName, key, true, func(ctx context.Context) error {
+		headers, err = bucket.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(headers.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	downloadOpts := getDownloadOptions(options)
+	object := &downloadObject{size: size}
+	if crc := headers.Get(oss.HTTPHeaderOssCRC64); !downloadOpts.noVerify && crc != "" {
+		object.hash = crc64.New(crc64.MakeTable(crc64.ECMA))
+		object.checksum = crc
+		object.sum = func(h hash.Hash) string {
+			return strconv.FormatUint(h.(hash.Hash64).Sum64(), 10)
 		}
+	}
+	return downloadRanges(ctx, object, w, downloadOpts, ossClient.retryPolicy, func(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+		return ossClient.getRange(ctx, bucket, key, offset, length)
+	})
+}
 
-		return string(decodedBytes), err
+func (ossClient *OSS) GetAndDecompress(key string) (string, error) {
+	return ossClient.GetAndDecompressWithContext(context.Background(), key)
+}
+
+func (ossClient *OSS) GetAndDecompressWithContext(ctx context.Context, key string) (string, error) {
+	body, err := ossClient.GetAndDecompressAsReaderWithContext(ctx, key)
+	if err != nil || body == nil {
+		return "", err
 	}
+	defer body.Close()
 
 	data, err := ioutil.ReadAll(body)
 	if err != nil {
 		return "", err
 	}
-
 	return string(data), nil
 }
 
 func (ossClient *OSS) GetAndDecompressAsReader(key string) (io.ReadCloser, error) {
-	ret, err := ossClient.GetAndDecompress(key)
-	if err != nil {
+	return ossClient.GetAndDecompressAsReaderWithContext(context.Background(), key)
+}
+
+func (ossClient *OSS) GetAndDecompressAsReaderWithContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	getOpts := DefaultGetOptions()
+	getOpts.raw = true
+	result, err := ossClient.get(ctx, key, getOpts)
+	if err != nil || result == nil {
 		return nil, err
 	}
-	return ioutil.NopCloser(strings.NewReader(ret)), nil
+
+	headers := result.Response.Headers
+	return decompressBody(result.Response, headers.Get("X-Oss-Meta-Compressor"), headers.Get(oss.HTTPHeaderContentEncoding))
 }
 
 func (ossClient *OSS) Put(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return ossClient.PutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (ossClient *OSS) PutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return ossClient.putObject(ctx, key, reader, meta, ossClient.compressor, options...)
+}
+
+// putObject puts the object, the body is compressed by compressor if it isn't nil
+func (ossClient *OSS) putObject(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, compressor Compressor, options ...PutOptions) error {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return err
@@ -199,55 +302,42 @@ func (ossClient *OSS) Put(key string, reader io.ReadSeeker, meta map[string]stri
 		opt(putOptions)
 	}
 
-	ossOptions := make([]oss.Option, 0)
-	if meta != nil {
-		for k, v := range meta {
-			ossOptions = append(ossOptions, oss.Meta(k, v))
-		}
-	}
-	ossOptions = append(ossOptions, oss.ContentType(putOptions.contentType))
-	if putOptions.contentEncoding != nil {
-		ossOptions = append(ossOptions, oss.ContentEncoding(*putOptions.contentEncoding))
-	}
-	if putOptions.contentDisposition != nil {
-		ossOptions = append(ossOptions, oss.ContentDisposition(*putOptions.contentDisposition))
-	}
-	if putOptions.cacheControl != nil {
-		ossOptions = append(ossOptions, oss.CacheControl(*putOptions.cacheControl))
-	}
-	if putOptions.expires != nil {
-		ossOptions = append(ossOptions, oss.Expires(*putOptions.expires))
-	}
-	if ossClient.compressor != nil {
-		readSeeker, l, err := GetReaderLength(reader)
+	ossOptions := getOSSPutOptions(meta, putOptions)
+	if compressor != nil && reader != nil {
+		body, err := compressBody(compressor, reader, ossClient.cfg.CompressLimit)
 		if err != nil {
 			return err
 		}
-		if l < ossClient.cfg.CompressLimit {
-			reader = readSeeker
-		} else {
-			var clen int64
-			reader, clen, err = ossClient.compressor.Compress(readSeeker)
-			if err != nil {
-				return err
-			}
-			ossOptions = append(ossOptions, oss.ContentLength(clen))
-			ossOptions = append(ossOptions, oss.ContentEncoding(ossClient.compressor.ContentEncoding()))
+		defer body.Close()
+		reader = body
+		if body.compressed {
+			encoding := compressor.ContentEncoding()
+			ossOptions = append(ossOptions, oss.ContentLength(body.size))
+			ossOptions = append(ossOptions, oss.ContentEncoding(encoding))
+			ossClient.cfg.observeCompression(StorageTypeOSS, bucket.BucketName, "PutObject", encoding, body.rawSize, body.size)
 		}
 	}
-	return retry.Do(func() error {
-		err := bucket.PutObject(key, reader, ossOptions...)
+	return ossClient.do(ctx, "PutObject", bucket.BucketName, key, true, func(ctx context.Context) error {
+		err := bucket.PutObject(key, reader, append(ossOptions, oss.WithContext(ctx))...)
 		if err != nil && reader != nil {
 			// Reset the body reader after the request since at this point it's already read
 			// Note that it's safe to ignore the error here since the 0,0 position is always valid
 			_, _ = reader.Seek(0, 0)
 		}
 		return err
-	}, retry.Attempts(3), retry.Delay(1*time.Second))
+	})
 }
 
 func (ossClient *OSS) CompressAndPut(key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
-	data, err := ioutil.ReadAll(reader)
+	return ossClient.CompressAndPutWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (ossClient *OSS) CompressAndPutWithContext(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	body, size, rawSize, err := compressForPut(putOptions.compressType, reader)
 	if err != nil {
 		return err
 	}
@@ -255,23 +345,100 @@ func (ossClient *OSS) CompressAndPut(key string, reader io.ReadSeeker, meta map[
 		meta = make(map[string]string)
 	}
 
-	encodedBytes := snappy.Encode(nil, data)
+	if bucket, err := ossClient.getBucket(key); err == nil {
+		ossClient.cfg.observeCompression(StorageTypeOSS, bucket.BucketName, "PutObject", putOptions.compressType, rawSize, size)
+	}
 
-	meta["Compressor"] = "snappy"
+	meta["Compressor"] = putOptions.compressType
+
+	return ossClient.PutWithContext(ctx, key, body, meta, options...)
+}
 
-	return ossClient.Put(key, bytes.NewReader(encodedBytes), meta, options...)
+// Upload uploads a large object in parts, objects smaller than a single part are sent in one request.
+// Unlike Put, the body is never compressed, whatever its size.
+func (ossClient *OSS) Upload(key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	return ossClient.UploadWithContext(context.Background(), key, reader, meta, options...)
+}
+
+func (ossClient *OSS) UploadWithContext(ctx context.Context, key string, reader io.Reader, meta map[string]string, options ...UploadOptions) error {
+	bucket, err := ossClient.getBucket(key)
+	if err != nil {
+		return err
+	}
+
+	uploadOpts := getUploadOptions(options)
+	first, drained, err := readPart(reader, uploadOpts.partSize)
+	if err != nil {
+		return err
+	}
+	if drained {
+		return ossClient.putObject(ctx, key, bytes.NewReader(first), meta, nil, uploadOpts.putOptions...)
+	}
+
+	putOptions := DefaultPutOptions()
+	for _, opt := range uploadOpts.putOptions {
+		opt(putOptions)
+	}
+	var imur oss.InitiateMultipartUploadResult
+	err = ossClient.do(ctx, "InitiateMultipartUpload", bucket.BucketName, key, false, func(ctx context.Context) error {
+		imur, err = bucket.InitiateMultipartUpload(key, append(getOSSPutOptions(meta, putOptions), oss.WithContext(ctx))...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	parts := make([]oss.UploadPart, 0)
+	err = uploadParts(ctx, first, reader, uploadOpts, ossClient.retryPolicy, func(ctx context.Context, partNumber int, part []byte) error {
+		var result oss.UploadPart
+		err := ossClient.invoke(ctx, "UploadPart", bucket.BucketName, key, func(ctx context.Context) error {
+			var err error
+			result, err = bucket.UploadPart(imur, bytes.NewReader(part), int64(len(part)), partNumber, oss.WithContext(ctx))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		parts = append(parts, result)
+		mu.Unlock()
+		return nil
+	})
+	if err == nil {
+		err = ossClient.do(ctx, "CompleteMultipartUpload", bucket.BucketName, key, false, func(ctx context.Context) error {
+			_, err := bucket.CompleteMultipartUpload(imur, parts, oss.WithContext(ctx))
+			return err
+		})
+	}
+	if err != nil {
+		// the context may already be done, abort without it so that the uploaded parts are released
+		_ = bucket.AbortMultipartUpload(imur)
+		return err
+	}
+	return nil
 }
 
 func (ossClient *OSS) Del(key string) error {
+	return ossClient.DelWithContext(context.Background(), key)
+}
+
+func (ossClient *OSS) DelWithContext(ctx context.Context, key string) error {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return err
 	}
 
-	return bucket.DeleteObject(key)
+	return ossClient.do(ctx, "DeleteObject", bucket.BucketName, key, true, func(ctx context.Context) error {
+		return bucket.DeleteObject(key, oss.WithContext(ctx))
+	})
 }
 
 func (ossClient *OSS) DelMulti(keys []string) error {
+	return ossClient.DelMultiWithContext(context.Background(), keys)
+}
+
+func (ossClient *OSS) DelMultiWithContext(ctx context.Context, keys []string) error {
 	bucketsKeys := make(map[*oss.Bucket][]string)
 	for _, key := range keys {
 		bucket, err := ossClient.getBucket(key)
@@ -282,7 +449,10 @@ func (ossClient *OSS) DelMulti(keys []string) error {
 	}
 
 	for bucket, bKeys := range bucketsKeys {
-		_, err := bucket.DeleteObjects(bKeys)
+		err := ossClient.do(ctx, "DeleteObjects", bucket.BucketName, "", true, func(ctx context.Context) error {
+			_, err := bucket.DeleteObjects(bKeys, oss.WithContext(ctx))
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -292,17 +462,23 @@ func (ossClient *OSS) DelMulti(keys []string) error {
 }
 
 func (ossClient *OSS) Head(key string, attributes []string) (map[string]string, error) {
+	return ossClient.HeadWithContext(context.Background(), key, attributes)
+}
+
+func (ossClient *OSS) HeadWithContext(ctx context.Context, key string, attributes []string) (map[string]string, error) {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return nil, err
 	}
 
-	headers, err := bucket.GetObjectDetailedMeta(key)
+	var headers http.Header
+	err = ossClient.do(ctx, "GetObjectMeta", bucket.BucketName, key, true, func(ctx context.Context) error {
+		headers, err = bucket.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+		return err
+	})
 	if err != nil {
-		if oerr, ok := err.(oss.ServiceError); ok {
-			if oerr.StatusCode == 404 {
-				return nil, nil
-			}
+		if ossClient.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -311,12 +487,23 @@ func (ossClient *OSS) Head(key string, attributes []string) (map[string]string,
 }
 
 func (ossClient *OSS) ListObject(key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return ossClient.ListObjectWithContext(context.Background(), key, prefix, marker, maxKeys, delimiter)
+}
+
+func (ossClient *OSS) ListObjectWithContext(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(maxKeys), oss.Delimiter(delimiter))
+	var res oss.ListObjectsResult
+	err = ossClient.do(ctx, "ListObjects", bucket.BucketName, "", true, func(ctx context.Context) error {
+		res, err = bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(maxKeys), oss.Delimiter(delimiter), oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 	keys := make([]string, 0)
 	for _, v := range res.Objects {
 		keys = append(keys, v.Key)
@@ -325,6 +512,75 @@ func (ossClient *OSS) ListObject(key string, prefix string, marker string, maxKe
 	return keys, nil
 }
 
+// List returns a page of objects with their metadata, by ListObjectsV2
+func (ossClient *OSS) List(ctx context.Context, input ListInput) (*ListResult, error) {
+	if input.AllShards && len(ossClient.Shards) > 0 {
+		buckets := make(map[string]*oss.Bucket)
+		bucketNames := make([]string, 0, len(ossClient.Shards))
+		for _, bucket := range ossClient.Shards {
+			if _, ok := buckets[bucket.BucketName]; !ok {
+				buckets[bucket.BucketName] = bucket
+				bucketNames = append(bucketNames, bucket.BucketName)
+			}
+		}
+		return listShards(ctx, bucketNames, input, func(ctx context.Context, bucketName string, input ListInput) (*ListResult, error) {
+			return ossClient.list(ctx, buckets[bucketName], input)
+		})
+	}
+
+	bucket, err := ossClient.getBucket(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	return ossClient.list(ctx, bucket, input)
+}
+
+func (ossClient *OSS) list(ctx context.Context, bucket *oss.Bucket, input ListInput) (*ListResult, error) {
+	ossOptions := make([]oss.Option, 0)
+	if input.Prefix != "" {
+		ossOptions = append(ossOptions, oss.Prefix(input.Prefix))
+	}
+	if input.Marker != "" {
+		ossOptions = append(ossOptions, oss.StartAfter(input.Marker))
+	}
+	if input.ContinuationToken != "" {
+		ossOptions = append(ossOptions, oss.ContinuationToken(input.ContinuationToken))
+	}
+	if input.MaxKeys > 0 {
+		ossOptions = append(ossOptions, oss.MaxKeys(input.MaxKeys))
+	}
+	if input.Delimiter != "" {
+		ossOptions = append(ossOptions, oss.Delimiter(input.Delimiter))
+	}
+
+	var result oss.ListObjectsResultV2
+	err := ossClient.do(ctx, "ListObjectsV2", bucket.BucketName, "", true, func(ctx context.Context) error {
+		var err error
+		result, err = bucket.ListObjectsV2(append(ossOptions, oss.WithContext(ctx))...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ListResult{
+		Objects:               make([]ObjectInfo, 0, len(result.Objects)),
+		CommonPrefixes:        append(make([]string, 0, len(result.CommonPrefixes)), result.CommonPrefixes...),
+		IsTruncated:           result.IsTruncated,
+		NextContinuationToken: result.NextContinuationToken,
+	}
+	for _, v := range result.Objects {
+		res.Objects = append(res.Objects, ObjectInfo{
+			Key:          v.Key,
+			Size:         v.Size,
+			ETag:         strings.Trim(v.ETag, `"`),
+			LastModified: v.LastModified,
+			StorageClass: v.StorageClass,
+		})
+	}
+	return res, nil
+}
+
 func (ossClient *OSS) SignURL(key string, expired int64, options ...SignOptions) (string, error) {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
@@ -341,11 +597,171 @@ func (ossClient *OSS) SignURL(key string, expired int64, options ...SignOptions)
 }
 
 func (ossClient *OSS) Exists(key string) (bool, error) {
+	return ossClient.ExistsWithContext(context.Background(), key)
+}
+
+func (ossClient *OSS) ExistsWithContext(ctx context.Context, key string) (bool, error) {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return false, err
 	}
-	return bucket.IsObjectExist(key)
+	var ok bool
+	err = ossClient.do(ctx, "GetObjectMeta", bucket.BucketName, key, true, func(ctx context.Context) error {
+		ok, err = bucket.IsObjectExist(key, oss.WithContext(ctx))
+		return err
+	})
+	return ok, err
+}
+
+// ListBucket is List on the named bucket regardless of the shard routing
+func (ossClient *OSS) ListBucket(ctx context.Context, bucket string, input ListInput) (*ListResult, error) {
+	b, err := ossClient.bucketByName(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return ossClient.list(ctx, b, input)
+}
+
+// StatBucketObject returns the attributes of the object in the named bucket, or nil if it does not exist
+func (ossClient *OSS) StatBucketObject(ctx context.Context, bucket string, key string) (*ObjectStat, error) {
+	b, err := ossClient.bucketByName(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var headers http.Header
+	err = ossClient.do(ctx, "GetObjectMeta", bucket, key, true, func(ctx context.Context) error {
+		headers, err = b.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	size, _ := strconv.ParseInt(headers.Get(oss.HTTPHeaderContentLength), 10, 64)
+	stat := &ObjectStat{
+		Size:            size,
+		ETag:            strings.Trim(headers.Get(oss.HTTPHeaderEtag), `"`),
+		ContentType:     headers.Get(oss.HTTPHeaderContentType),
+		ContentEncoding: headers.Get(oss.HTTPHeaderContentEncoding),
+		Meta:            make(map[string]string),
+	}
+	for k := range headers {
+		if strings.HasPrefix(strings.ToLower(k), strings.ToLower(oss.HTTPHeaderOssMetaPrefix)) {
+			stat.Meta[strings.ToLower(k[len(oss.HTTPHeaderOssMetaPrefix):])] = headers.Get(k)
+		}
+	}
+	return stat, nil
+}
+
+// CopyBetweenBuckets copies the object server-side with its metadata and content headers,
+// objects larger than 1GB are copied by multipart
+func (ossClient *OSS) CopyBetweenBuckets(ctx context.Context, srcBucket string, dstBucket string, key string) error {
+	src, err := ossClient.bucketByName(srcBucket)
+	if err != nil {
+		return err
+	}
+	dst, err := ossClient.bucketByName(dstBucket)
+	if err != nil {
+		return err
+	}
+	var headers http.Header
+	err = ossClient.do(ctx, "GetObjectMeta", srcBucket, key, true, func(ctx context.Context) error {
+		headers, err = src.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	size, err := strconv.ParseInt(headers.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		return err
+	}
+	if size <= ossCopyObjectLimit {
+		return ossClient.do(ctx, "CopyObject", dstBucket, key, true, func(ctx context.Context) error {
+			_, err := dst.CopyObjectFrom(srcBucket, key, key, oss.WithContext(ctx))
+			return err
+		})
+	}
+
+	ossOptions := make([]oss.Option, 0)
+	for k := range headers {
+		if strings.HasPrefix(strings.ToLower(k), strings.ToLower(oss.HTTPHeaderOssMetaPrefix)) {
+			ossOptions = append(ossOptions, oss.Meta(k[len(oss.HTTPHeaderOssMetaPrefix):], headers.Get(k)))
+		}
+	}
+	for _, h := range []string{oss.HTTPHeaderContentType, oss.HTTPHeaderContentEncoding, oss.HTTPHeaderContentDisposition, oss.HTTPHeaderCacheControl} {
+		if v := headers.Get(h); v != "" {
+			ossOptions = append(ossOptions, oss.SetHeader(h, v))
+		}
+	}
+	var imur oss.InitiateMultipartUploadResult
+	err = ossClient.do(ctx, "InitiateMultipartUpload", dstBucket, key, false, func(ctx context.Context) error {
+		imur, err = dst.InitiateMultipartUpload(key, append(ossOptions, oss.WithContext(ctx))...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	parts := make([]oss.UploadPart, 0)
+	for offset, partNumber := int64(0), 1; offset < size; offset, partNumber = offset+copyPartSize, partNumber+1 {
+		partSize := copyPartSize
+		if offset+partSize > size {
+			partSize = size - offset
+		}
+		var part oss.UploadPart
+		err = ossClient.do(ctx, "UploadPartCopy", dstBucket, key, true, func(ctx context.Context) error {
+			part, err = dst.UploadPartCopy(imur, srcBucket, key, offset, partSize, partNumber, oss.WithContext(ctx))
+			return err
+		})
+		if err != nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+	if err == nil {
+		err = ossClient.do(ctx, "CompleteMultipartUpload", dstBucket, key, false, func(ctx context.Context) error {
+			_, err := dst.CompleteMultipartUpload(imur, parts, oss.WithContext(ctx))
+			return err
+		})
+	}
+	if err != nil {
+		_ = dst.AbortMultipartUpload(imur)
+		return err
+	}
+	return nil
+}
+
+// DelFromBucket deletes the object from the named bucket regardless of the shard routing
+func (ossClient *OSS) DelFromBucket(ctx context.Context, bucket string, key string) error {
+	b, err := ossClient.bucketByName(bucket)
+	if err != nil {
+		return err
+	}
+	return ossClient.do(ctx, "DeleteObject", bucket, key, true, func(ctx context.Context) error {
+		return b.DeleteObject(key, oss.WithContext(ctx))
+	})
+}
+
+// bucketByName returns the bucket with the same oss client as the configured ones
+func (ossClient *OSS) bucketByName(name string) (*oss.Bucket, error) {
+	reference := ossClient.Bucket
+	for _, bucket := range ossClient.Shards {
+		if bucket.BucketName == name {
+			return bucket, nil
+		}
+		reference = bucket
+	}
+	if reference == nil {
+		return nil, errors.New("oss client has no bucket")
+	}
+	if reference.BucketName == name {
+		return reference, nil
+	}
+	return reference.Client.Bucket(name)
 }
 
 func getOSSMeta(attributes []string, headers http.Header) map[string]string {
@@ -359,31 +775,58 @@ func getOSSMeta(attributes []string, headers http.Header) map[string]string {
 	return meta
 }
 
-func getOSSOptions(getOpts *getOptions) []oss.Option {
+func getOSSPutOptions(meta map[string]string, putOptions *putOptions) []oss.Option {
+	ossOptions := make([]oss.Option, 0)
+	if meta != nil {
+		for k, v := range meta {
+			ossOptions = append(ossOptions, oss.Meta(k, v))
+		}
+	}
+	ossOptions = append(ossOptions, oss.ContentType(putOptions.contentType))
+	if putOptions.contentEncoding != nil {
+		ossOptions = append(ossOptions, oss.ContentEncoding(*putOptions.contentEncoding))
+	}
+	if putOptions.contentDisposition != nil {
+		ossOptions = append(ossOptions, oss.ContentDisposition(*putOptions.contentDisposition))
+	}
+	if putOptions.cacheControl != nil {
+		ossOptions = append(ossOptions, oss.CacheControl(*putOptions.cacheControl))
+	}
+	if putOptions.expires != nil {
+		ossOptions = append(ossOptions, oss.Expires(*putOptions.expires))
+	}
+	return ossOptions
+}
+
+func (ossClient *OSS) getOSSOptions(getOpts *getOptions) []oss.Option {
 	ossOpts := make([]oss.Option, 0)
+	if encoding := ossClient.cfg.acceptEncoding(getOpts); encoding != "" {
+		ossOpts = append(ossOpts, oss.AcceptEncoding(encoding))
+	}
 	if getOpts.contentEncoding != nil {
-		ossOpts = append(ossOpts, oss.ContentEncoding(*getOpts.contentEncoding))
+		ossOpts = append(ossOpts, oss.ResponseContentEncoding(*getOpts.contentEncoding))
 	}
 	if getOpts.contentType != nil {
-		ossOpts = append(ossOpts, oss.ContentEncoding(*getOpts.contentType))
+		ossOpts = append(ossOpts, oss.ResponseContentType(*getOpts.contentType))
 	}
 
 	return ossOpts
 }
 
-func (ossClient *OSS) get(key string, options *getOptions) (*oss.GetObjectResult, error) {
+func (ossClient *OSS) get(ctx context.Context, key string, options *getOptions) (*oss.GetObjectResult, error) {
 	bucket, err := ossClient.getBucket(key)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, getOSSOptions(options))
-
+	var result *oss.GetObjectResult
+	err = ossClient.do(ctx, "GetObject", bucket.BucketName, key, true, func(ctx context.Context) error {
+		result, err = bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, append(ossClient.getOSSOptions(options), oss.WithContext(ctx)))
+		return err
+	})
 	if err != nil {
-		if oerr, ok := err.(oss.ServiceError); ok {
-			if oerr.StatusCode == 404 {
-				return nil, nil
-			}
+		if ossClient.notFoundAsNil() && IsNotFound(err) {
+			return nil, nil
 		}
 		return nil, err
 	}
@@ -391,6 +834,55 @@ func (ossClient *OSS) get(key string, options *getOptions) (*oss.GetObjectResult
 	return result, nil
 }
 
+func (ossClient *OSS) notFoundAsNil() bool {
+	return ossClient.cfg != nil && ossClient.cfg.NotFoundAsNil
+}
+
+// do sends a request of op with the retry policy, see invoke
+func (ossClient *OSS) do(ctx context.Context, op string, bucket string, key string, idempotent bool, fn func(ctx context.Context) error) error {
+	return ossClient.retryPolicy.do(ctx, idempotent, func(ctx context.Context) error {
+		return ossClient.invoke(ctx, op, bucket, key, fn)
+	})
+}
+
+// invoke sends a single attempt of a request of op through the interceptors, the error is converted to *Error
+func (ossClient *OSS) invoke(ctx context.Context, op string, bucket string, key string, fn func(ctx context.Context) error) error {
+	info := &requestInfo{StorageType: StorageTypeOSS, Op: op, Bucket: bucket, Shard: ossClient.shardOf(bucket), Key: key}
+	return runInterceptors(ctx, ossClient.interceptors, info, func(err error) error {
+		return ossClient.wrapError(op, bucket, key, err)
+	}, fn)
+}
+
+// shardOf returns the shard of the bucket, "" without shards
+func (ossClient *OSS) shardOf(bucket string) string {
+	for shard, b := range ossClient.Shards {
+		if b.BucketName == bucket {
+			return shard
+		}
+	}
+	return ""
+}
+
+// wrapError converts the error of the sdk to *Error
+func (ossClient *OSS) wrapError(op string, bucket string, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	res := &Error{StorageType: StorageTypeOSS, Op: op, Bucket: bucket, Key: key, Err: err}
+	switch oerr := err.(type) {
+	case oss.ServiceError:
+		res.Code = oerr.Code
+		res.StatusCode = oerr.StatusCode
+		res.RequestID = oerr.RequestID
+	case oss.UnexpectedStatusCodeError:
+		res.StatusCode = oerr.Got()
+	}
+	return res
+}
+
 func extractOSSRequestID(resp *oss.Response) string {
 	if resp == nil {
 		return ""
@@ -6,12 +6,19 @@ Put your environment configuration in ".env-oss"
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/golang/snappy"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,12 +33,13 @@ const (
 	compressContent = "snappy-contentsnappy-contentsnappy-contentsnappy-content"
 )
 
-var (
-	ossClient Client
-)
-
-func init() {
-
+// liveOSSClient returns the client of the storage configured by the environment, e.g. StorageType, AccessKeyID and Bucket.
+// The test is skipped without StorageType, so that the other tests run without a live storage.
+func liveOSSClient(t *testing.T) Client {
+	t.Helper()
+	if os.Getenv("StorageType") == "" {
+		t.Skip("StorageType is not set, the live oss tests are skipped")
+	}
 	client, err := New(&Options{
 		StorageType:      os.Getenv("StorageType"),
 		AccessKeyID:      os.Getenv("AccessKeyID"),
@@ -42,15 +50,14 @@ func init() {
 		S3ForcePathStyle: os.Getenv("S3ForcePathStyle") == "true",
 		SSL:              os.Getenv("SSL") == "true",
 	})
-
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
-
-	ossClient = client
+	return client
 }
 
 func TestOSS_Put(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	meta := make(map[string]string)
 	meta["head"] = strconv.Itoa(expectHead)
 	meta["length"] = strconv.Itoa(expectLength)
@@ -69,6 +76,7 @@ func TestOSS_Put(t *testing.T) {
 }
 
 func TestOSS_CompressAndPut(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	meta := make(map[string]string)
 	meta["head"] = strconv.Itoa(expectHead)
 	meta["length"] = strconv.Itoa(expectLength)
@@ -87,6 +95,7 @@ func TestOSS_CompressAndPut(t *testing.T) {
 }
 
 func TestOSS_Head(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head")
 	var res map[string]string
@@ -128,6 +137,7 @@ func TestOSS_Head(t *testing.T) {
 }
 
 func TestOSS_Get(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	res, err := ossClient.Get(guid)
 	if err != nil || res != content {
 		t.Log("oss get content fail, res:", res, "err:", err)
@@ -158,6 +168,7 @@ func TestOSS_Get(t *testing.T) {
 }
 
 func TestOSS_GetWithMeta(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head")
 	res, meta, err := ossClient.GetWithMeta(guid, attributes)
@@ -178,6 +189,7 @@ func TestOSS_GetWithMeta(t *testing.T) {
 }
 
 func TestOSS_GetAndDecompress(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	reader, meta, err := ossClient.GetWithMeta(compressGUID, []string{MetaCompressor})
 	if err != nil {
 		t.Log("oss get error", err)
@@ -215,6 +227,7 @@ func TestOSS_GetAndDecompress(t *testing.T) {
 }
 
 func TestOSS_GetAndDecompress2(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	_, meta, err := ossClient.GetWithMeta(guid, []string{MetaCompressor})
 	if err != nil {
 		t.Log("oss get error", err)
@@ -240,6 +253,7 @@ func TestOSS_GetAndDecompress2(t *testing.T) {
 }
 
 func TestOSS_SignURL(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	res, err := ossClient.SignURL(guid, 60)
 	if err != nil {
 		t.Log("oss signUrl fail, res:", res, "err:", err)
@@ -248,6 +262,7 @@ func TestOSS_SignURL(t *testing.T) {
 }
 
 func TestOSS_ListObject(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	res, err := ossClient.ListObject(guid, guid[0:4], "", 10, "")
 	if err != nil || len(res) == 0 {
 		t.Log("oss list objects fail, res:", res, "err:", err)
@@ -256,6 +271,7 @@ func TestOSS_ListObject(t *testing.T) {
 }
 
 func TestOSS_Del(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	err := ossClient.Del(guid)
 	if err != nil {
 		t.Log("oss del key fail, err:", err)
@@ -264,6 +280,7 @@ func TestOSS_Del(t *testing.T) {
 }
 
 func TestOSS_DelMulti(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	keys := []string{"aaa", "bb0", "ccc"}
 	for _, key := range keys {
 		ossClient.Put(key, strings.NewReader("2333333"), nil)
@@ -277,7 +294,7 @@ func TestOSS_DelMulti(t *testing.T) {
 
 	for _, key := range keys {
 		res, err := ossClient.Get(key)
-		if res != "" || err != nil {
+		if res != "" || !IsNotFound(err) {
 			t.Logf("key:%s should not be exist", key)
 			t.Fail()
 		}
@@ -285,8 +302,9 @@ func TestOSS_DelMulti(t *testing.T) {
 }
 
 func TestOSS_GetNotExist(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	res1, err := ossClient.Get(guid + "123")
-	if res1 != "" || err != nil {
+	if res1 != "" || !errors.Is(err, ErrNotFound) {
 		t.Log("oss get not exist key fail, res:", res1, "err:", err)
 		t.Fail()
 	}
@@ -294,13 +312,14 @@ func TestOSS_GetNotExist(t *testing.T) {
 	attributes := make([]string, 0)
 	attributes = append(attributes, "head")
 	res2, err := ossClient.Head(guid+"123", attributes)
-	if res2 != nil || err != nil {
+	if res2 != nil || !IsNotFound(err) {
 		t.Log("oss head not exist key fail, res:", res2, "err:", err)
 		t.Fail()
 	}
 }
 
 func TestOSS_Range(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	meta := make(map[string]string)
 	err := ossClient.Put(guid, strings.NewReader("123456"), meta)
 	if err != nil {
@@ -321,6 +340,7 @@ func TestOSS_Range(t *testing.T) {
 }
 
 func TestOSS_Exists(t *testing.T) {
+	ossClient := liveOSSClient(t)
 	meta := make(map[string]string)
 	err := ossClient.Put(guid, strings.NewReader("123456"), meta)
 	if err != nil {
@@ -356,3 +376,75 @@ func TestOSS_Exists(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestOSSHTTPClient(t *testing.T) {
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "7")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("con"))
+		w.(http.Flusher).Flush()
+		// the rest of the body never comes
+		<-stall
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	// the read/write timeout of the sdk ends a stalled body
+	client, err := oss.New(server.URL, "ak", "sk", oss.Timeout(1, 1), ossHTTPClient(newConfig(&Options{})))
+	assert.NoError(t, err)
+	bucket, err := client.Bucket("test")
+	assert.NoError(t, err)
+	body, err := bucket.GetObject("key")
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = ioutil.ReadAll(body)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	body.Close()
+
+	// the proxy of the sdk
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	client, err = oss.New("http://oss.example.com", "ak", "sk", oss.Proxy(proxy.URL), ossHTTPClient(newConfig(&Options{})))
+	assert.NoError(t, err)
+	bucket, err = client.Bucket("test")
+	assert.NoError(t, err)
+	assert.NoError(t, bucket.PutObject("key", strings.NewReader("content")))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&proxied))
+}
+
+func TestOSS_GetWithMetaGZIP(t *testing.T) {
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test/key" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+			return
+		}
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("X-Oss-Meta-Head", "1")
+		_, _ = w.Write([]byte("gzipped"))
+	}))
+	defer server.Close()
+
+	client, err := New(&Options{StorageType: StorageTypeOSS, AccessKeyID: "ak", AccessKeySecret: "sk", Endpoint: server.URL, Bucket: "test"})
+	assert.NoError(t, err)
+	body, meta, err := client.GetWithMetaGZIP("key", []string{"head", "Content-Encoding"})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "gzipped", string(data))
+	assert.Equal(t, "gzip", acceptEncoding)
+	assert.Equal(t, map[string]string{"head": "1", "Content-Encoding": "gzip"}, meta)
+
+	_, _, err = client.GetWithMetaGZIP("missing", nil)
+	assert.True(t, IsNotFound(err))
+}
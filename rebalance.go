@@ -0,0 +1,369 @@
+package awos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// copyPartSize is the part size of the multipart copy of large objects
+const copyPartSize = int64(256 << 20)
+
+// DefaultRebalanceConcurrency default number of objects moved at the same time
+const DefaultRebalanceConcurrency = 8
+
+// ObjectStat is the attributes of an object compared after copying, Meta keys are lowercase
+type ObjectStat struct {
+	Size            int64
+	ETag            string
+	ContentType     string
+	ContentEncoding string
+	Meta            map[string]string
+}
+
+// BucketClient operates on named buckets regardless of the shard routing, implemented by S3 and OSS
+type BucketClient interface {
+	ListBucket(ctx context.Context, bucket string, input ListInput) (*ListResult, error)
+	// StatBucketObject returns nil, nil if the object does not exist
+	StatBucketObject(ctx context.Context, bucket string, key string) (*ObjectStat, error)
+	CopyBetweenBuckets(ctx context.Context, srcBucket string, dstBucket string, key string) error
+	DelFromBucket(ctx context.Context, bucket string, key string) error
+}
+
+// ShardLayout is the Bucket/Shards/ShardRouter part of Options
+type ShardLayout struct {
+	Bucket string
+	Shards []string
+	// Optional, route by the last character of the key if nil
+	ShardRouter ShardRouter
+}
+
+// Buckets returns every bucket of the layout
+func (l ShardLayout) Buckets() []string {
+	if len(l.Shards) == 0 {
+		return []string{l.Bucket}
+	}
+	res := make([]string, 0, len(l.Shards))
+	for _, shard := range l.Shards {
+		res = append(res, l.Bucket+"-"+shard)
+	}
+	sort.Strings(res)
+	return dedupSorted(res)
+}
+
+// BucketOf returns the bucket of the key
+func (l ShardLayout) BucketOf(key string) (string, error) {
+	if len(l.Shards) == 0 {
+		return l.Bucket, nil
+	}
+	router := l.ShardRouter
+	if router == nil {
+		router = NewLastCharRouter(l.Shards)
+	}
+	shard, err := router.Route(key)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range l.Shards {
+		if v == shard {
+			return l.Bucket + "-" + shard, nil
+		}
+	}
+	return "", errors.New("shards can't find bucket")
+}
+
+// RebalanceCheckpoint is the progress of a rebalance
+type RebalanceCheckpoint struct {
+	// Markers the last processed key of each source bucket
+	Markers map[string]string `json:"markers"`
+	// Done the source buckets which have been processed completely
+	Done []string `json:"done"`
+}
+
+func (c *RebalanceCheckpoint) isDone(bucket string) bool {
+	for _, v := range c.Done {
+		if v == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckpointStore saves the progress so that an interrupted rebalance resumes
+type CheckpointStore interface {
+	// Load returns an empty checkpoint if nothing has been saved
+	Load() (*RebalanceCheckpoint, error)
+	Save(checkpoint *RebalanceCheckpoint) error
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore saves the checkpoint as json in the file
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{path: path}
+}
+
+func (f *fileCheckpointStore) Load() (*RebalanceCheckpoint, error) {
+	checkpoint := &RebalanceCheckpoint{Markers: make(map[string]string)}
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", f.path, err)
+	}
+	if checkpoint.Markers == nil {
+		checkpoint.Markers = make(map[string]string)
+	}
+	return checkpoint, nil
+}
+
+func (f *fileCheckpointStore) Save(checkpoint *RebalanceCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// RebalanceOptions for NewRebalancer
+type RebalanceOptions struct {
+	// Required, the layout the objects are stored in
+	Old ShardLayout
+	// Required, the layout the objects are moved to
+	New ShardLayout
+	// Optional, only move the objects whose key begins with the prefix
+	Prefix string
+	// Optional, delete the source object after it is copied and verified
+	DeleteSource bool
+	// Optional, number of objects moved at the same time, default DefaultRebalanceConcurrency
+	Concurrency int
+	// Optional, number of keys listed per page, default 1000
+	PageSize int
+	// Optional, resume from and save the progress to the store
+	Checkpoint CheckpointStore
+}
+
+// RebalanceStats counts the objects processed by Run
+type RebalanceStats struct {
+	// Scanned objects listed in the old buckets
+	Scanned int64
+	// Skipped objects already in the right bucket
+	Skipped int64
+	// Copied objects copied to the new bucket
+	Copied int64
+	// Existing objects already present in the new bucket, which are never overwritten
+	Existing int64
+	// Deleted source objects deleted
+	Deleted int64
+}
+
+// Rebalancer moves the objects whose bucket changes between two shard layouts
+type Rebalancer struct {
+	client  BucketClient
+	options RebalanceOptions
+}
+
+// NewRebalancer creates a Rebalancer, client is the *S3 or *OSS created by New
+func NewRebalancer(client BucketClient, options *RebalanceOptions) (*Rebalancer, error) {
+	if client == nil {
+		return nil, errors.New("rebalance client is nil")
+	}
+	if options.Old.Bucket == "" || options.New.Bucket == "" {
+		return nil, errors.New("rebalance layouts require Bucket")
+	}
+	opts := *options
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultRebalanceConcurrency
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultMaxKeys
+	}
+	return &Rebalancer{client: client, options: opts}, nil
+}
+
+// Run lists every old bucket and moves the misplaced objects, it stops at the first error,
+// run it again with the same Checkpoint to resume.
+// Objects already present in the new bucket are considered newer, e.g. written by a DualReadClient, and are never overwritten.
+func (r *Rebalancer) Run(ctx context.Context) (*RebalanceStats, error) {
+	stats := &RebalanceStats{}
+	checkpoint := &RebalanceCheckpoint{Markers: make(map[string]string)}
+	if r.options.Checkpoint != nil {
+		var err error
+		checkpoint, err = r.options.Checkpoint.Load()
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	for _, bucket := range r.options.Old.Buckets() {
+		if checkpoint.isDone(bucket) {
+			continue
+		}
+		input := ListInput{Prefix: r.options.Prefix, Marker: checkpoint.Markers[bucket], MaxKeys: r.options.PageSize}
+		for {
+			result, err := r.client.ListBucket(ctx, bucket, input)
+			if err != nil {
+				return stats, fmt.Errorf("list bucket %s failed: %w", bucket, err)
+			}
+			if err := r.movePage(ctx, bucket, result.Objects, stats); err != nil {
+				return stats, err
+			}
+
+			if n := len(result.Objects); n > 0 {
+				checkpoint.Markers[bucket] = result.Objects[n-1].Key
+			}
+			if !result.IsTruncated || result.NextContinuationToken == "" {
+				delete(checkpoint.Markers, bucket)
+				checkpoint.Done = append(checkpoint.Done, bucket)
+			}
+			if r.options.Checkpoint != nil {
+				if err := r.options.Checkpoint.Save(checkpoint); err != nil {
+					return stats, err
+				}
+			}
+			if checkpoint.isDone(bucket) {
+				break
+			}
+			input.Marker = ""
+			input.ContinuationToken = result.NextContinuationToken
+		}
+	}
+	return stats, nil
+}
+
+func (r *Rebalancer) movePage(ctx context.Context, bucket string, objects []ObjectInfo, stats *RebalanceStats) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, r.options.Concurrency)
+	)
+	for _, object := range objects {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := r.move(ctx, bucket, key, stats); err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("move %s/%s failed: %w", bucket, key, err)
+					cancel()
+				})
+			}
+		}(object.Key)
+	}
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+func (r *Rebalancer) move(ctx context.Context, bucket string, key string, stats *RebalanceStats) error {
+	atomic.AddInt64(&stats.Scanned, 1)
+	dstBucket, err := r.options.New.BucketOf(key)
+	if err != nil {
+		return err
+	}
+	if dstBucket == bucket {
+		atomic.AddInt64(&stats.Skipped, 1)
+		return nil
+	}
+
+	dst, err := r.client.StatBucketObject(ctx, dstBucket, key)
+	if err != nil {
+		return err
+	}
+	if dst != nil {
+		atomic.AddInt64(&stats.Existing, 1)
+	} else {
+		src, err := r.client.StatBucketObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		if src == nil {
+			// deleted after being listed
+			return nil
+		}
+		if err := r.client.CopyBetweenBuckets(ctx, bucket, dstBucket, key); err != nil {
+			return err
+		}
+		dst, err = r.client.StatBucketObject(ctx, dstBucket, key)
+		if err != nil {
+			return err
+		}
+		if err := verifyCopy(src, dst); err != nil {
+			return err
+		}
+		atomic.AddInt64(&stats.Copied, 1)
+	}
+
+	if r.options.DeleteSource {
+		if err := r.client.DelFromBucket(ctx, bucket, key); err != nil {
+			return err
+		}
+		atomic.AddInt64(&stats.Deleted, 1)
+	}
+	return nil
+}
+
+// verifyCopy compares the copied object with the source, the ETag of a multipart object
+// depends on the part size, so it's only compared when neither is multipart
+func verifyCopy(src *ObjectStat, dst *ObjectStat) error {
+	if dst == nil {
+		return errors.New("copied object does not exist")
+	}
+	if src.Size != dst.Size {
+		return fmt.Errorf("size mismatch after copy, source:%d, copied:%d", src.Size, dst.Size)
+	}
+	if !strings.Contains(src.ETag, "-") && !strings.Contains(dst.ETag, "-") && !strings.EqualFold(src.ETag, dst.ETag) {
+		return fmt.Errorf("etag mismatch after copy, source:%s, copied:%s", src.ETag, dst.ETag)
+	}
+	if src.ContentEncoding != dst.ContentEncoding || src.ContentType != dst.ContentType {
+		return errors.New("content headers mismatch after copy")
+	}
+	if len(src.Meta) != len(dst.Meta) {
+		return errors.New("metadata mismatch after copy")
+	}
+	for k, v := range src.Meta {
+		if dst.Meta[k] != v {
+			return fmt.Errorf("metadata %s mismatch after copy", k)
+		}
+	}
+	return nil
+}
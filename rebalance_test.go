@@ -0,0 +1,135 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBucketClient struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*ObjectStat
+	failKey string
+}
+
+func (f *fakeBucketClient) ListBucket(ctx context.Context, bucket string, input ListInput) (*ListResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0)
+	for key := range f.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	marker := input.Marker
+	if input.ContinuationToken != "" {
+		marker = input.ContinuationToken
+	}
+	res := &ListResult{}
+	for _, key := range keys {
+		if key <= marker {
+			continue
+		}
+		if len(res.Objects) == input.MaxKeys {
+			res.IsTruncated = true
+			res.NextContinuationToken = res.Objects[len(res.Objects)-1].Key
+			break
+		}
+		res.Objects = append(res.Objects, ObjectInfo{Key: key})
+	}
+	return res, nil
+}
+
+func (f *fakeBucketClient) StatBucketObject(ctx context.Context, bucket string, key string) (*ObjectStat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buckets[bucket][key], nil
+}
+
+func (f *fakeBucketClient) CopyBetweenBuckets(ctx context.Context, srcBucket string, dstBucket string, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == f.failKey {
+		return errors.New("internal error")
+	}
+	if f.buckets[dstBucket] == nil {
+		f.buckets[dstBucket] = make(map[string]*ObjectStat)
+	}
+	stat := *f.buckets[srcBucket][key]
+	f.buckets[dstBucket][key] = &stat
+	return nil
+}
+
+func (f *fakeBucketClient) DelFromBucket(ctx context.Context, bucket string, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.buckets[bucket], key)
+	return nil
+}
+
+func TestShardLayout(t *testing.T) {
+	layout := ShardLayout{Bucket: "content", Shards: []string{"def", "abc"}}
+	assert.Equal(t, []string{"content-abc", "content-def"}, layout.Buckets())
+	bucket, err := layout.BucketOf("key-e")
+	assert.NoError(t, err)
+	assert.Equal(t, "content-def", bucket)
+
+	layout = ShardLayout{Bucket: "content"}
+	assert.Equal(t, []string{"content"}, layout.Buckets())
+}
+
+func TestRebalancer_Run(t *testing.T) {
+	stat := func(etag string) *ObjectStat {
+		return &ObjectStat{Size: 1, ETag: etag, ContentEncoding: "gzip", Meta: map[string]string{"head": "1"}}
+	}
+	client := &fakeBucketClient{
+		buckets: map[string]map[string]*ObjectStat{
+			"content-ab": {"1a": stat("1"), "1b": stat("2"), "2b": stat("3")},
+			"content-cd": {"1c": stat("4"), "1d": stat("5")},
+			// written to the new layout by a DualReadClient
+			"content-b": {"2b": stat("6")},
+		},
+		failKey: "1c",
+	}
+	checkpoint := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+	rebalancer, err := NewRebalancer(client, &RebalanceOptions{
+		Old:          ShardLayout{Bucket: "content", Shards: []string{"ab", "cd"}},
+		New:          ShardLayout{Bucket: "content", Shards: []string{"a", "b", "c", "d"}},
+		DeleteSource: true,
+		PageSize:     1,
+		Checkpoint:   checkpoint,
+	})
+	assert.NoError(t, err)
+
+	// the only object of content-cd to move fails, the content-ab bucket is done
+	_, err = rebalancer.Run(context.Background())
+	assert.EqualError(t, err, "move content-cd/1c failed: internal error")
+	saved, err := checkpoint.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"content-ab"}, saved.Done)
+
+	client.failKey = ""
+	stats, err := rebalancer.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &RebalanceStats{Scanned: 2, Copied: 2, Deleted: 2}, stats)
+
+	assert.Equal(t, stat("1"), client.buckets["content-a"]["1a"])
+	assert.Equal(t, stat("2"), client.buckets["content-b"]["1b"])
+	assert.Equal(t, stat("6"), client.buckets["content-b"]["2b"])
+	assert.Equal(t, stat("4"), client.buckets["content-c"]["1c"])
+	assert.Empty(t, client.buckets["content-ab"])
+	assert.Empty(t, client.buckets["content-cd"])
+}
+
+func TestVerifyCopy(t *testing.T) {
+	src := &ObjectStat{Size: 1, ETag: "abc", Meta: map[string]string{"head": "1"}}
+	assert.NoError(t, verifyCopy(src, &ObjectStat{Size: 1, ETag: "ABC", Meta: map[string]string{"head": "1"}}))
+	assert.NoError(t, verifyCopy(&ObjectStat{Size: 1, ETag: "abc-2"}, &ObjectStat{Size: 1, ETag: "def"}))
+	assert.Error(t, verifyCopy(src, &ObjectStat{Size: 2, ETag: "abc", Meta: map[string]string{"head": "1"}}))
+	assert.Error(t, verifyCopy(src, &ObjectStat{Size: 1, ETag: "abc", Meta: map[string]string{"head": "2"}}))
+	assert.Error(t, verifyCopy(src, &ObjectStat{Size: 1, ETag: "abc", ContentEncoding: "gzip", Meta: map[string]string{"head": "1"}}))
+}
@@ -0,0 +1,220 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const (
+	// DefaultRetryAttempts default number of attempts of a request, including the first one
+	DefaultRetryAttempts = 3
+	// DefaultRetryBaseDelay default delay before the first retry
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	// DefaultRetryMaxDelay default max delay between two attempts
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// RetryPolicy decides how the requests to oss/s3 are retried, it applies to every operation of the Client
+type RetryPolicy struct {
+	// Optional, number of attempts including the first one, 1 disables retries, default DefaultRetryAttempts
+	MaxAttempts int
+	// Optional, the delay before the first retry, doubled for each retry with jitter, default DefaultRetryBaseDelay
+	BaseDelay time.Duration
+	// Optional, the max delay between two attempts, also caps Retry-After, default DefaultRetryMaxDelay
+	MaxDelay time.Duration
+	// Optional, whether the error is worth retrying, default IsRetryable
+	Retryable func(err error) bool
+	// Optional, also retry the requests which are not idempotent, i.e. initiating and completing multipart uploads
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns the policy used when Options.RetryPolicy is nil
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultRetryAttempts,
+		BaseDelay:   DefaultRetryBaseDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+		Retryable:   IsRetryable,
+	}
+}
+
+// newRetryPolicy fills the unset fields of the policy with the defaults
+func newRetryPolicy(policy *RetryPolicy) *RetryPolicy {
+	res := DefaultRetryPolicy()
+	if policy == nil {
+		return res
+	}
+	if policy.MaxAttempts > 0 {
+		res.MaxAttempts = policy.MaxAttempts
+	}
+	if policy.BaseDelay > 0 {
+		res.BaseDelay = policy.BaseDelay
+	}
+	if policy.MaxDelay > 0 {
+		res.MaxDelay = policy.MaxDelay
+	}
+	if policy.Retryable != nil {
+		res.Retryable = policy.Retryable
+	}
+	res.RetryNonIdempotent = policy.RetryNonIdempotent
+	return res
+}
+
+// withAttempts returns a copy of the policy with the number of attempts, used by the parts of Upload and Download
+func (p *RetryPolicy) withAttempts(attempts int) *RetryPolicy {
+	res := *newRetryPolicy(p)
+	if attempts > 0 {
+		res.MaxAttempts = attempts
+	}
+	return &res
+}
+
+// delay returns the delay before the n-th retry (from 0), Retry-After of the server wins if it's longer
+func (p *RetryPolicy) delay(n uint, retryAfter time.Duration) time.Duration {
+	d := p.MaxDelay
+	if n < 32 && p.BaseDelay<<n < p.MaxDelay {
+		d = p.BaseDelay << n
+	}
+	// equal jitter, half of the delay is random
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	if retryAfter > d {
+		d = retryAfter
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// do calls fn until it succeeds, the error is not retryable, the attempts run out or the context is done.
+// fn must use the context it receives so that Retry-After of the responses is honored.
+func (p *RetryPolicy) do(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	if p == nil {
+		p = DefaultRetryPolicy()
+	}
+	attempts := p.MaxAttempts
+	if attempts <= 0 || (!idempotent && !p.RetryNonIdempotent) {
+		attempts = 1
+	}
+	hint := &retryHint{}
+	ctx = context.WithValue(ctx, retryHintKey{}, hint)
+	var retryAfter time.Duration
+	return retry.Do(func() error {
+		hint.set(0)
+		return fn(ctx)
+	},
+		retry.Attempts(uint(attempts)),
+		retry.RetryIf(p.Retryable),
+		retry.OnRetry(func(n uint, err error) {
+			retryAfter = hint.get()
+		}),
+		retry.DelayType(func(n uint, _ *retry.Config) time.Duration {
+			return p.delay(n, retryAfter)
+		}),
+		retry.Context(ctx),
+		retry.LastErrorOnly(true),
+	)
+}
+
+// IsRetryable reports whether the request may succeed if it's sent again:
+// 5xx, throttling, connection resets and timeouts are retryable, other 4xx and cancellations are not
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var awosErr *Error
+	if errors.As(err, &awosErr) && awosErr.StatusCode != 0 {
+		return awosErr.StatusCode >= http.StatusInternalServerError || errors.Is(err, ErrThrottled)
+	}
+	if errors.Is(err, ErrThrottled) {
+		return true
+	}
+
+	// errors of the sdk before a response is received, the aws sdk keeps the cause in OrigErr
+	for err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+			errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return true
+		}
+		msg := err.Error()
+		if strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") {
+			return true
+		}
+		if aerr, ok := err.(awserr.Error); ok {
+			err = aerr.OrigErr()
+			continue
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+type retryHintKey struct{}
+
+// retryHint carries the Retry-After of the last response from the transport to RetryPolicy.do
+type retryHint struct {
+	retryAfter int64
+}
+
+func (h *retryHint) set(d time.Duration) {
+	atomic.StoreInt64(&h.retryAfter, int64(d))
+}
+
+func (h *retryHint) get() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.retryAfter))
+}
+
+// retryAfterTransport records the Retry-After header of the responses into the retryHint of the request context
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			hint.set(d)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter parses the Retry-After header, either seconds or an http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package awos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&Error{StatusCode: http.StatusInternalServerError}))
+	assert.True(t, IsRetryable(&Error{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown"}))
+	assert.True(t, IsRetryable(&Error{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, IsRetryable(&Error{StatusCode: http.StatusNotFound, Code: "NoSuchKey"}))
+	assert.False(t, IsRetryable(&Error{StatusCode: http.StatusForbidden}))
+	assert.True(t, IsRetryable(&Error{Err: awserr.New("RequestError", "send request failed", syscall.ECONNRESET)}))
+	assert.True(t, IsRetryable(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.False(t, IsRetryable(errors.New("invalid argument")))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := newRetryPolicy(&RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	for n := uint(0); n < 10; n++ {
+		d := policy.delay(n, 0)
+		assert.LessOrEqual(t, int64(d), int64(time.Second))
+		assert.GreaterOrEqual(t, int64(d), int64(50*time.Millisecond))
+	}
+	assert.Equal(t, time.Second, policy.delay(0, 5*time.Second))
+	assert.Equal(t, 800*time.Millisecond, policy.delay(0, 800*time.Millisecond))
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := newRetryPolicy(&RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	var calls int
+	err := policy.do(context.Background(), true, func(ctx context.Context) error {
+		calls++
+		return &Error{StatusCode: http.StatusInternalServerError}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = policy.do(context.Background(), false, func(ctx context.Context) error {
+		calls++
+		return &Error{StatusCode: http.StatusInternalServerError}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = policy.do(context.Background(), true, func(ctx context.Context) error {
+		calls++
+		return &Error{StatusCode: http.StatusNotFound}
+	})
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicy_RetryAfter(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					fmt.Fprint(w, "<Error><Code>SlowDown</Code></Error>")
+					return
+				}
+				fmt.Fprint(w, "content")
+			}))
+			defer server.Close()
+
+			client, err := New(&Options{
+				StorageType:      storageType,
+				AccessKeyID:      "ak",
+				AccessKeySecret:  "sk",
+				Endpoint:         server.URL,
+				Bucket:           "test",
+				Region:           "cn-north-1",
+				S3ForcePathStyle: true,
+				RetryPolicy:      &RetryPolicy{BaseDelay: time.Millisecond},
+			})
+			assert.NoError(t, err)
+
+			start := time.Now()
+			res, err := client.Get("key")
+			assert.NoError(t, err)
+			assert.Equal(t, "content", res)
+			assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+		})
+	}
+}
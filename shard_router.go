@@ -0,0 +1,171 @@
+package awos
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultVirtualNodes default number of virtual nodes of every shard on the consistent hash ring
+const DefaultVirtualNodes = 160
+
+var errEmptyShardKey = errors.New("shards can't route empty key")
+
+// ShardRouter chooses the shard of a key, the returned shard is one of Options.Shards,
+// and the key is stored in the bucket named Options.Bucket + "-" + shard
+type ShardRouter interface {
+	Route(key string) (string, error)
+}
+
+// ShardRouterFunc adapts a function to ShardRouter
+type ShardRouterFunc func(key string) (string, error)
+
+func (f ShardRouterFunc) Route(key string) (string, error) {
+	return f(key)
+}
+
+// routeLegacy routes by the lowercase last character of the key,
+// used when the shard maps are keyed by character, i.e. S3/OSS is built without New
+func routeLegacy(key string) (string, error) {
+	if key == "" {
+		return "", errEmptyShardKey
+	}
+	return strings.ToLower(key[len(key)-1:]), nil
+}
+
+func routeShard(router ShardRouter, key string) (string, error) {
+	if router == nil {
+		return routeLegacy(key)
+	}
+	return router.Route(key)
+}
+
+type lastCharRouter struct {
+	shards map[string]string
+}
+
+// NewLastCharRouter routes by the lowercase last character of the key,
+// if shards is ['abc', 'def'], the key ending with a/b/c goes to shard 'abc'
+func NewLastCharRouter(shards []string) ShardRouter {
+	router := &lastCharRouter{shards: make(map[string]string)}
+	for _, v := range shards {
+		for i := 0; i < len(v); i++ {
+			router.shards[strings.ToLower(v[i:i+1])] = v
+		}
+	}
+	return router
+}
+
+func (r *lastCharRouter) Route(key string) (string, error) {
+	char, err := routeLegacy(key)
+	if err != nil {
+		return "", err
+	}
+	shard, ok := r.shards[char]
+	if !ok {
+		return "", fmt.Errorf("shards can't find bucket for the last character %q", char)
+	}
+	return shard, nil
+}
+
+type hashModRouter struct {
+	shards []string
+}
+
+// NewHashModRouter routes by the crc32 hash of the key modulo the number of shards
+func NewHashModRouter(shards []string) ShardRouter {
+	return &hashModRouter{shards: append([]string(nil), shards...)}
+}
+
+func (r *hashModRouter) Route(key string) (string, error) {
+	if len(r.shards) == 0 {
+		return "", errors.New("shards can't find bucket")
+	}
+	return r.shards[hashKey(key)%uint32(len(r.shards))], nil
+}
+
+type ringNode struct {
+	hash  uint32
+	shard string
+}
+
+type consistentHashRouter struct {
+	ring []ringNode
+}
+
+// NewConsistentHashRouter routes by a consistent hash ring on which every shard has virtualNodes nodes,
+// so that adding a shard only moves about 1/n of the keys. virtualNodes <= 0 means DefaultVirtualNodes.
+func NewConsistentHashRouter(shards []string, virtualNodes int) ShardRouter {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	router := &consistentHashRouter{ring: make([]ringNode, 0, len(shards)*virtualNodes)}
+	for _, shard := range shards {
+		for i := 0; i < virtualNodes; i++ {
+			router.ring = append(router.ring, ringNode{hash: hashKey(shard + "#" + strconv.Itoa(i)), shard: shard})
+		}
+	}
+	sort.Slice(router.ring, func(i, j int) bool {
+		if router.ring[i].hash != router.ring[j].hash {
+			return router.ring[i].hash < router.ring[j].hash
+		}
+		return router.ring[i].shard < router.ring[j].shard
+	})
+	return router
+}
+
+func (r *consistentHashRouter) Route(key string) (string, error) {
+	if len(r.ring) == 0 {
+		return "", errors.New("shards can't find bucket")
+	}
+	h := hashKey(key)
+	i := sort.Search(len(r.ring), func(i int) bool {
+		return r.ring[i].hash >= h
+	})
+	if i == len(r.ring) {
+		i = 0
+	}
+	return r.ring[i].shard, nil
+}
+
+type prefixRoute struct {
+	prefix string
+	shard  string
+}
+
+type prefixRouter struct {
+	routes   []prefixRoute
+	fallback ShardRouter
+}
+
+// NewPrefixRouter routes by the longest prefix of the key found in routes (prefix => shard),
+// keys matching no prefix go to fallback, or fail if fallback is nil
+func NewPrefixRouter(routes map[string]string, fallback ShardRouter) ShardRouter {
+	router := &prefixRouter{fallback: fallback}
+	for prefix, shard := range routes {
+		router.routes = append(router.routes, prefixRoute{prefix: prefix, shard: shard})
+	}
+	sort.Slice(router.routes, func(i, j int) bool {
+		return len(router.routes[i].prefix) > len(router.routes[j].prefix)
+	})
+	return router
+}
+
+func (r *prefixRouter) Route(key string) (string, error) {
+	for _, route := range r.routes {
+		if strings.HasPrefix(key, route.prefix) {
+			return route.shard, nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback.Route(key)
+	}
+	return "", fmt.Errorf("shards can't find bucket for key %q", key)
+}
+
+func hashKey(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
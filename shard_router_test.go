@@ -0,0 +1,100 @@
+package awos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastCharRouter(t *testing.T) {
+	router := NewLastCharRouter([]string{"abc", "def"})
+
+	shard, err := router.Route("key-A")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", shard)
+
+	shard, err = router.Route("key-f")
+	assert.NoError(t, err)
+	assert.Equal(t, "def", shard)
+
+	_, err = router.Route("key-z")
+	assert.Error(t, err)
+
+	_, err = router.Route("")
+	assert.Equal(t, errEmptyShardKey, err)
+}
+
+func TestHashModRouter(t *testing.T) {
+	router := NewHashModRouter([]string{"abc", "def"})
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		shard, err := router.Route(fmt.Sprintf("doc/%d.json", i))
+		assert.NoError(t, err)
+		counts[shard]++
+	}
+	assert.Len(t, counts, 2)
+
+	// empty keys and keys ending with any character can be routed
+	_, err := router.Route("")
+	assert.NoError(t, err)
+}
+
+func TestConsistentHashRouter(t *testing.T) {
+	before := NewConsistentHashRouter([]string{"a", "b", "c"}, 0)
+	after := NewConsistentHashRouter([]string{"a", "b", "c", "d"}, 0)
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("doc/%d", i)
+		s1, _ := before.Route(key)
+		s2, _ := after.Route(key)
+		if s1 != s2 {
+			// keys only move to the new shard
+			assert.Equal(t, "d", s2)
+			moved++
+		}
+	}
+	assert.InDelta(t, 2500, moved, 700)
+}
+
+func TestPrefixRouter(t *testing.T) {
+	router := NewPrefixRouter(map[string]string{
+		"img/":       "images",
+		"img/thumb/": "thumbs",
+	}, nil)
+
+	shard, err := router.Route("img/thumb/1.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "thumbs", shard)
+
+	shard, err = router.Route("img/1.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "images", shard)
+
+	_, err = router.Route("doc/1")
+	assert.Error(t, err)
+
+	router = NewPrefixRouter(map[string]string{"img/": "images"}, NewHashModRouter([]string{"docs"}))
+	shard, err = router.Route("doc/1")
+	assert.NoError(t, err)
+	assert.Equal(t, "docs", shard)
+}
+
+func TestS3_getBucket(t *testing.T) {
+	// built by hand with character keys
+	client := &S3{ShardsBucket: map[string]string{"a": "content-abc", "d": "content-def"}}
+	bucketName, err := client.getBucket("key-A")
+	assert.NoError(t, err)
+	assert.Equal(t, "content-abc", bucketName)
+	_, err = client.getBucket("")
+	assert.Error(t, err)
+
+	client = &S3{
+		ShardsBucket: map[string]string{"abc": "content-abc", "def": "content-def"},
+		ShardRouter:  NewLastCharRouter([]string{"abc", "def"}),
+	}
+	bucketName, err = client.getBucket("key-e")
+	assert.NoError(t, err)
+	assert.Equal(t, "content-def", bucketName)
+}
@@ -0,0 +1,133 @@
+package awos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/shimohq/awos/v3"
+
+// attributes of the spans
+const (
+	attrStorageType        = attribute.Key("awos.storage_type")
+	attrBucket             = attribute.Key("awos.bucket")
+	attrKey                = attribute.Key("awos.key")
+	attrShard              = attribute.Key("awos.shard")
+	attrRequestID          = attribute.Key("awos.request_id")
+	attrErrorCode          = attribute.Key("awos.error_code")
+	attrStatusCode         = attribute.Key("http.status_code")
+	attrRequestContentLen  = attribute.Key("http.request_content_length")
+	attrResponseContentLen = attribute.Key("http.response_content_length")
+)
+
+const (
+	ossRequestIDHeader = "X-Oss-Request-Id"
+	s3TraceHandlerName = "awos.trace"
+)
+
+// traceInterceptor starts a client span for every request, the span is the child of the span in the context of the caller
+func traceInterceptor(cfg *Config) interceptor {
+	provider := cfg.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	tracer := provider.Tracer(tracerName)
+	return func(ctx context.Context, info *requestInfo, next func(ctx context.Context) error) error {
+		attrs := []attribute.KeyValue{
+			attrStorageType.String(info.StorageType),
+			attrBucket.String(info.Bucket),
+		}
+		if info.Key != "" {
+			attrs = append(attrs, attrKey.String(cfg.traceKey(info.Key)))
+		}
+		if info.Shard != "" {
+			attrs = append(attrs, attrShard.String(info.Shard))
+		}
+		ctx, span := tracer.Start(ctx, info.StorageType+"."+info.Op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		err := next(ctx)
+		if err != nil {
+			var awosErr *Error
+			if errors.As(err, &awosErr) {
+				if awosErr.StatusCode != 0 {
+					span.SetAttributes(attrStatusCode.Int(awosErr.StatusCode))
+				}
+				if awosErr.Code != "" {
+					span.SetAttributes(attrErrorCode.String(awosErr.Code))
+				}
+				if awosErr.RequestID != "" {
+					span.SetAttributes(attrRequestID.String(awosErr.RequestID))
+				}
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+// traceKey returns the key recorded in the spans, its sha256 with HashTraceKey
+func (c *Config) traceKey(key string) string {
+	if !c.HashTraceKey {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// traceS3Request records the response of s3 into the span of the request, it's a Complete handler of the s3 client
+func traceS3Request(r *request.Request) {
+	span := trace.SpanFromContext(r.Context())
+	if !span.IsRecording() {
+		return
+	}
+	if r.HTTPRequest != nil && r.HTTPRequest.ContentLength > 0 {
+		span.SetAttributes(attrRequestContentLen.Int64(r.HTTPRequest.ContentLength))
+	}
+	if r.HTTPResponse != nil {
+		span.SetAttributes(attrStatusCode.Int(r.HTTPResponse.StatusCode))
+		if r.HTTPResponse.ContentLength >= 0 {
+			span.SetAttributes(attrResponseContentLen.Int64(r.HTTPResponse.ContentLength))
+		}
+	}
+	if r.RequestID != "" {
+		span.SetAttributes(attrRequestID.String(r.RequestID))
+	}
+}
+
+// traceTransport records the response of oss into the span of the request, the oss sdk has no hooks like s3
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return t.base.RoundTrip(req)
+	}
+	if req.ContentLength > 0 {
+		span.SetAttributes(attrRequestContentLen.Int64(req.ContentLength))
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	span.SetAttributes(attrStatusCode.Int(resp.StatusCode))
+	if resp.ContentLength >= 0 {
+		span.SetAttributes(attrResponseContentLen.Int64(resp.ContentLength))
+	}
+	if id := resp.Header.Get(ossRequestIDHeader); id != "" {
+		span.SetAttributes(attrRequestID.String(id))
+	}
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package awos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	res := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		res[kv.Key] = kv.Value
+	}
+	return res
+}
+
+func TestTracing(t *testing.T) {
+	for _, storageType := range []string{StorageTypeS3, StorageTypeOSS} {
+		t.Run(storageType, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Amz-Request-Id", "req-1")
+				w.Header().Set("X-Oss-Request-Id", "req-1")
+				if strings.HasSuffix(r.URL.Path, "/missing") {
+					w.Header().Set("Content-Type", "application/xml")
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><RequestId>req-2</RequestId></Error>")
+					return
+				}
+				fmt.Fprint(w, "content")
+			}))
+			defer server.Close()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			client, err := New(&Options{
+				StorageType:      storageType,
+				AccessKeyID:      "ak",
+				AccessKeySecret:  "sk",
+				Endpoint:         server.URL,
+				Bucket:           "test",
+				Shards:           []string{"y", "g"},
+				Region:           "cn-north-1",
+				S3ForcePathStyle: true,
+				RetryPolicy:      &RetryPolicy{MaxAttempts: 1},
+				TracerProvider:   provider,
+			})
+			assert.NoError(t, err)
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "save")
+			res, err := client.GetWithContext(ctx, "key")
+			assert.NoError(t, err)
+			assert.Equal(t, "content", res)
+			_, err = client.GetWithContext(ctx, "missing")
+			assert.True(t, IsNotFound(err))
+			parent.End()
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 3)
+			get := spans[0]
+			assert.Equal(t, storageType+".GetObject", get.Name())
+			assert.Equal(t, trace.SpanKindClient, get.SpanKind())
+			assert.Equal(t, parent.SpanContext().SpanID(), get.Parent().SpanID())
+			attrs := spanAttributes(get)
+			assert.Equal(t, "test-y", attrs[attrBucket].AsString())
+			assert.Equal(t, "y", attrs[attrShard].AsString())
+			assert.Equal(t, "key", attrs[attrKey].AsString())
+			assert.Equal(t, int64(http.StatusOK), attrs[attrStatusCode].AsInt64())
+			assert.Equal(t, int64(len("content")), attrs[attrResponseContentLen].AsInt64())
+			assert.Equal(t, "req-1", attrs[attrRequestID].AsString())
+
+			missing := spans[1]
+			assert.Equal(t, "Error", missing.Status().Code.String())
+			attrs = spanAttributes(missing)
+			assert.Equal(t, "g", attrs[attrShard].AsString())
+			assert.Equal(t, int64(http.StatusNotFound), attrs[attrStatusCode].AsInt64())
+			assert.Equal(t, "NoSuchKey", attrs[attrErrorCode].AsString())
+		})
+	}
+}
+
+func TestTracing_HashTraceKey(t *testing.T) {
+	cfg := &Config{BucketConfig: BucketConfig{HashTraceKey: true}}
+	assert.Equal(t, "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683", cfg.traceKey("key"))
+	cfg.HashTraceKey = false
+	assert.Equal(t, "key", cfg.traceKey("key"))
+}
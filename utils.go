@@ -1,6 +1,10 @@
 package awos
 
-import "io"
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
 
 // CombinedReadCloser combined a ReadCloser and a Readers to a new ReaderCloser
 // which will read from reader and close origin closer
@@ -17,3 +21,41 @@ func (combined CombinedReadCloser) Read(b []byte) (int, error) {
 func (combined CombinedReadCloser) Close() error {
 	return combined.ReadCloser.Close()
 }
+
+// objectHeaders returns the metadata and the standard headers of an object the way the s3 sdk returns them,
+// i.e. for getS3Meta, the content type and encoding are replaced by the ones of the GetOptions
+func objectHeaders(meta map[string]string, size int64, contentType string, contentEncoding *string, contentDisposition *string,
+	options []GetOptions) map[string]*string {
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	res := make(map[string]*string, len(meta)+4)
+	for k, v := range meta {
+		v := v
+		res[http.CanonicalHeaderKey(k)] = &v
+	}
+	contentLength := strconv.FormatInt(size, 10)
+	res["Content-Length"] = &contentLength
+	res["Content-Type"] = &contentType
+	res["Content-Encoding"] = contentEncoding
+	res["Content-Disposition"] = contentDisposition
+	if getOpts.contentType != nil {
+		res["Content-Type"] = getOpts.contentType
+	}
+	if getOpts.contentEncoding != nil {
+		res["Content-Encoding"] = getOpts.contentEncoding
+	}
+	return res
+}
+
+// compressionOf returns the Compressor meta of CompressAndPut and the Content-Encoding of the headers of objectHeaders
+func compressionOf(headers map[string]*string) (compressor string, encoding string) {
+	if v := headers["Compressor"]; v != nil {
+		compressor = *v
+	}
+	if v := headers["Content-Encoding"]; v != nil {
+		encoding = *v
+	}
+	return compressor, encoding
+}